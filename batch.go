@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+)
+
+// batchJob is one render of a batch, as given and as resolved
+type batchJob struct {
+	Source string // file:line or job file the job came from
+	Args   []string
+	Job    renderJob
+
+	Err      error
+	Duration time.Duration
+}
+
+// errSkipped marks the jobs an interrupt kept from starting
+var errSkipped = errors.New("skipped after interrupt")
+
+// readBatchJobs reads the job command lines of a batch argument. Job files (.toml, .yaml,
+// .yml, .json) are a single job; any other file lists one command line per line.
+func readBatchJobs(path string) ([]batchJob, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml", ".yaml", ".yml", ".json":
+		return []batchJob{{Source: path, Args: []string{"--config", path}}}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var jobs []batchJob
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		args, err := splitCommandLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		jobs = append(jobs, batchJob{Source: fmt.Sprintf("%s:%d", path, n), Args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return jobs, nil
+}
+
+// splitCommandLine splits a job line into arguments at spaces outside single or double quotes
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// resolveBatchJob parses and checks a job's command line without exiting on errors
+func resolveBatchJob(args []string) (renderJob, error) {
+	rf := newRenderFlags("batch job", flag.ContinueOnError)
+	rf.fs.SetOutput(io.Discard)
+	if err := rf.fs.Parse(args); err != nil {
+		return renderJob{}, err
+	}
+	if *rf.help || *rf.helpLong || *rf.listPresets {
+		return renderJob{}, fmt.Errorf("a batch job must render something")
+	}
+	if *rf.checkpoint != "" || *rf.resume || *rf.shard != "" {
+		return renderJob{}, fmt.Errorf("batch jobs share one enumeration and cannot checkpoint or shard it")
+	}
+	return rf.resolve(rf.fs.Args())
+}
+
+// printBatchUsage prints the batch subcommand help
+func printBatchUsage(progName string) {
+	fmt.Printf("Usage: %s batch [flags] FILE...\n", progName)
+	fmt.Printf("  Runs many renders in one invocation, computing the algebraic numbers once for all of them.\n")
+	fmt.Printf("  A .toml, .yaml or .json FILE is a single job file (as for --config). Any other FILE lists\n")
+	fmt.Printf("  one job per line, written as the render flags and corners of a normal command line.\n")
+	fmt.Printf("  Blank lines and # comments are ignored, and arguments may be quoted.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --parallel N      Jobs rendered at once (default: number of CPUs, %d)\n", runtime.NumCPU())
+	printProgressUsage()
+	printResourceUsage()
+	printLogUsage()
+	printProfileUsage()
+	fmt.Printf("\nExample job list:\n")
+	fmt.Printf("  --preset golden-ratio --output golden.png\n")
+	fmt.Printf("  --preset near-i --zoom 4 --color-by mahler --output near_i.png\n")
+	fmt.Printf("  --max-height 12 --caption \"Unit square\" --output square.png -- -1 -1 1 1\n")
+	fmt.Printf("  --config poster.toml --output poster_draft.png\n")
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s batch jobs.txt\n", progName)
+	fmt.Printf("  %s batch --parallel 2 poster.toml near_i.yaml\n", progName)
+}
+
+// runBatch implements the batch subcommand
+func runBatch(progName string, args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	parallel := fs.Int("parallel", runtime.NumCPU(), "Jobs rendered at once")
+	progress := fs.String("progress", "text", "Enumeration progress on stderr: "+progressModes)
+	rs := addResourceFlags(fs)
+	lf := addLogFlags(fs)
+	pf := addProfileFlags(fs)
+	fs.Usage = func() {
+		printBatchUsage(progName)
+	}
+	fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *parallel < 1 {
+		fatalf("parallel must be at least 1")
+	}
+	if err := checkProgressMode(*progress); err != nil {
+		fatalf("%v", err)
+	}
+	if err := rs.apply(); err != nil {
+		fatalf("%v", err)
+	}
+
+	var jobs []batchJob
+	for _, path := range fs.Args() {
+		fileJobs, err := readBatchJobs(path)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		jobs = append(jobs, fileJobs...)
+	}
+	if len(jobs) == 0 {
+		fatalf("no jobs to run")
+	}
+
+	// Check every job before computing anything, so a typo in the last job is not found an hour in
+	invalid := 0
+	outputs := make(map[string]string)
+	maxHeight := 0
+	for i := range jobs {
+		job := &jobs[i]
+		job.Job, job.Err = resolveBatchJob(job.Args)
+		if job.Err == nil && job.Job.Config.OutputFile == "-" {
+			job.Err = fmt.Errorf("batch jobs cannot write to stdout, which has the summary")
+		} else if job.Err == nil {
+			output := filepath.Clean(job.Job.Config.OutputFile)
+			if first, ok := outputs[output]; ok {
+				job.Err = fmt.Errorf("output %s is also written by %s", job.Job.Config.OutputFile, first)
+			} else {
+				outputs[output] = job.Source
+			}
+		}
+		if job.Err != nil {
+			slog.Error(job.Err.Error(), "job", job.Source)
+			invalid++
+			continue
+		}
+		if job.Job.Config.MaxHeight > maxHeight {
+			maxHeight = job.Job.Config.MaxHeight
+		}
+	}
+	if invalid > 0 {
+		fatalf("%d of %d jobs are invalid", invalid, len(jobs))
+	}
+
+	// The jobs share one enumeration, so they share its seed too
+	seed, seededBy := int64(0), ""
+	for _, job := range jobs {
+		if !job.Job.seedGiven() {
+			continue
+		}
+		if seededBy != "" && job.Job.Config.Seed != seed {
+			fatalf("%s and %s give different seeds; a batch shares one enumeration", seededBy, job.Source)
+		}
+		seed, seededBy = job.Job.Config.Seed, job.Source
+	}
+	if seed == 0 {
+		seed = enumerate.NewSeed()
+	}
+	family := jobs[0].Job.Family
+	for _, job := range jobs[1:] {
+		if job.Job.Family != family {
+			fatalf("%s and %s enumerate different families; a batch shares one enumeration", jobs[0].Source, job.Source)
+		}
+	}
+	for i := range jobs {
+		jobs[i].Job.setSeed(seed)
+		if workers := jobs[i].Job.flags.resources.workers; *workers == 0 {
+			*workers = *rs.workers // Also for classifying the job's points
+		}
+	}
+	for _, job := range jobs {
+		if err := job.Job.dumpConfig(); err != nil {
+			fatalf("%s: %v", job.Source, err)
+		}
+	}
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+
+	// Enumerate once at the largest height; each job takes the prefix up to its own.
+	// After an interrupt during the enumeration every job renders what finished; after one
+	// during rendering the running jobs write partial output and the rest are skipped.
+	ctx, stop := interruptContext()
+	defer stop()
+	slog.Info("Calculating algebraic numbers", "max_height", maxHeight, "jobs", len(jobs))
+	start := time.Now()
+	opts := enumerate.Options{Progress: progressReporter(*progress), Workers: *rs.workers, Family: family, Logger: slog.Default()}
+	points, enum, err := enumerate.Run(ctx, maxHeight, seed, opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	sorted := enumerate.SortByHeight(points)
+	enumeration := time.Since(start)
+	renderCtx := afterInterrupt(ctx)
+
+	var mu sync.Mutex
+	done := 0
+	jobCh := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *parallel && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobCh {
+				job := &jobs[i]
+				if renderCtx.Err() != nil {
+					job.Err = errSkipped
+					continue
+				}
+				slog.Info("Rendering job", "job", fmt.Sprintf("%d/%d", i+1, len(jobs)),
+					"output", job.Job.Config.OutputFile, "source", job.Source)
+
+				jobStart := time.Now()
+				job.Err = job.Job.run(renderCtx, enumerate.UpToHeight(sorted, job.Job.Config.MaxHeight), enum)
+				job.Duration = time.Since(jobStart)
+
+				mu.Lock()
+				done++
+				attrs := []any{"job", fmt.Sprintf("%d/%d", i+1, len(jobs)), "output", job.Job.Config.OutputFile,
+					"duration", job.Duration.Round(time.Millisecond), "finished", done}
+				if job.Err != nil {
+					slog.Error("Job failed: "+job.Err.Error(), attrs...)
+				} else {
+					slog.Info("Job done", attrs...)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range jobs {
+		jobCh <- i
+	}
+	close(jobCh)
+	wg.Wait()
+
+	failed := printBatchSummary(jobs, enumeration, time.Since(start))
+	if enum.StoppedAt > 0 {
+		slog.Warn("Enumeration " + enumerate.DescribeStop(enum.StoppedAt) + ", so every output is partial")
+	}
+	exitIfInterrupted(ctx)
+	if failed > 0 {
+		stopProfiling()
+		os.Exit(1)
+	}
+}
+
+// printBatchSummary prints a table of the finished jobs and returns how many failed or were skipped
+func printBatchSummary(jobs []batchJob, enumeration, total time.Duration) int {
+	width := len("Output")
+	for _, job := range jobs {
+		if n := len(job.Job.Config.OutputFile); n > width {
+			width = n
+		}
+	}
+
+	failed, skipped := 0, 0
+	fmt.Printf("\nBatch summary:\n")
+	fmt.Printf("  %-7s %10s  %-*s  %s\n", "Status", "Time", width, "Output", "Source")
+	for _, job := range jobs {
+		status := "ok"
+		switch {
+		case job.Err == errSkipped:
+			status = "skipped"
+			skipped++
+		case job.Err != nil:
+			status = "FAILED"
+			failed++
+		}
+		fmt.Printf("  %-7s %10s  %-*s  %s\n", status, job.Duration.Round(time.Millisecond), width, job.Job.Config.OutputFile, job.Source)
+		if job.Err != nil && job.Err != errSkipped {
+			fmt.Printf("          %v\n", job.Err)
+		}
+	}
+	fmt.Printf("\n%d jobs, %d succeeded, %d failed", len(jobs), len(jobs)-failed-skipped, failed)
+	if skipped > 0 {
+		fmt.Printf(", %d skipped", skipped)
+	}
+	fmt.Printf("; enumeration %s, total %s\n", enumeration.Round(time.Millisecond), total.Round(time.Millisecond))
+	return failed + skipped
+}
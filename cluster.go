@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+)
+
+// parseListenAddr splits an address into a network and address for net.Listen and net.Dial:
+// unix:PATH is a Unix socket, anything else a TCP host:port
+func parseListenAddr(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
+
+// printCoordinatorUsage prints the coordinator subcommand help
+func printCoordinatorUsage(progName string) {
+	fmt.Printf("Usage: %s coordinator [flags] [-- x_min y_min x_max y_max]\n", progName)
+	fmt.Printf("  Renders like %s itself, but has worker processes solve the polynomials. Start any\n", progName)
+	fmt.Printf("  number of workers, on this or other machines, with %s worker --connect ADDR.\n", progName)
+	fmt.Printf("  Workers may come and go; the batches of one that disconnects are handed out again.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --listen ADDR     Address to serve workers on: host:port, or unix:PATH (default: localhost:7070)\n")
+	fmt.Printf("  --batch-size N    Polynomials per batch (default: 1000)\n")
+	fmt.Printf("  --lease D         Hand a batch out again if its worker has not returned it after D (default: 2m)\n")
+	fmt.Printf("  Any render, logging or profiling flag of %s itself, e.g. --max-height, --seed, --output or --center\n", progName)
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s coordinator --listen :7070 --max-height 24 --output h24.png\n", progName)
+	fmt.Printf("  %s coordinator --listen unix:/tmp/algebraic.sock --max-height 16\n", progName)
+}
+
+// runCoordinator implements the coordinator subcommand
+func runCoordinator(progName string, args []string) {
+	rf := newRenderFlags("coordinator", flag.ExitOnError)
+	lf := addLogFlags(rf.fs)
+	pf := addProfileFlags(rf.fs)
+	listen := rf.fs.String("listen", "localhost:7070", "Address to serve workers on")
+	batchSize := rf.fs.Int("batch-size", enumerate.DefaultBatchSize, "Polynomials per batch")
+	lease := rf.fs.Duration("lease", enumerate.DefaultLease, "Time a worker may hold a batch")
+	rf.fs.Usage = func() {
+		printCoordinatorUsage(progName)
+	}
+	rf.fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *rf.help || *rf.helpLong {
+		printCoordinatorUsage(progName)
+		return
+	}
+	if *rf.shard != "" || *rf.checkpoint != "" || *rf.resume {
+		fatalf("the coordinator cannot shard or checkpoint its enumeration")
+	}
+	if *batchSize < 1 {
+		fatalf("batch-size must be at least 1")
+	}
+	if *lease <= 0 {
+		fatalf("lease must be positive")
+	}
+	job, err := rf.resolve(rf.fs.Args())
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	network, address := parseListenAddr(*listen)
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+	renderFromOrExit(job, func(ctx context.Context) ([]enumerate.Point, enumerate.Enumeration, error) {
+		slog.Info("Calculating algebraic numbers")
+		opts := enumerate.Options{Family: job.Family, BatchSize: *batchSize, Lease: *lease, Progress: progressReporter(*rf.progress), Logger: slog.Default()}
+		return enumerate.Coordinate(ctx, ln, job.Config.MaxHeight, job.Config.Seed, opts)
+	})
+}
+
+// printWorkerUsage prints the worker subcommand help
+func printWorkerUsage(progName string) {
+	fmt.Printf("Usage: %s worker [flags]\n", progName)
+	fmt.Printf("  Solves batches of polynomials for a coordinator until its enumeration is done.\n")
+	fmt.Printf("  The worker must be the same build as the coordinator.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --connect ADDR    Coordinator address: host:port, or unix:PATH (default: localhost:7070)\n")
+	fmt.Printf("  --name NAME       Name shown by the coordinator (default: host name and process ID)\n")
+	fmt.Printf("  --wait D          Keep trying to reach the coordinator for D (default: 30s)\n")
+	printResourceUsage()
+	fmt.Printf("  --threads N       Deprecated name of --workers\n")
+	printLogUsage()
+	printProfileUsage()
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s worker --connect render-host:7070\n", progName)
+	fmt.Printf("  %s worker --connect unix:/tmp/algebraic.sock --workers 2\n", progName)
+}
+
+// runWorker implements the worker subcommand
+func runWorker(progName string, args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	connect := fs.String("connect", "localhost:7070", "Coordinator address")
+	threads := fs.Int("threads", 0, "Deprecated name of --workers")
+	name := fs.String("name", "", "Name shown by the coordinator")
+	wait := fs.Duration("wait", 30*time.Second, "Time to keep trying to reach the coordinator")
+	rs := addResourceFlags(fs)
+	lf := addLogFlags(fs)
+	pf := addProfileFlags(fs)
+	fs.Usage = func() {
+		printWorkerUsage(progName)
+	}
+	fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := rs.apply(); err != nil {
+		fatalf("%v", err)
+	}
+	workers := *rs.workers
+	if *threads != 0 {
+		slog.Warn("--threads is deprecated; use --workers")
+		if *threads < 0 {
+			fatalf("threads must be at least 1")
+		}
+		if workers == 0 {
+			workers = *threads
+		}
+	}
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	if *name == "" {
+		host, _ := os.Hostname()
+		*name = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	// The coordinator may still be starting
+	network, address := parseListenAddr(*connect)
+	var conn net.Conn
+	var err error
+	for deadline := time.Now().Add(*wait); ; time.Sleep(time.Second) {
+		if conn, err = net.Dial(network, address); err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	worker, err := enumerate.NewWorker(conn, *name)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer worker.Close()
+	slog.Info("Connected to the coordinator", "address", *connect, "name", *name, "workers", workers)
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+
+	// An interrupted worker just leaves; the coordinator hands its batches to others
+	ctx, stop := interruptContext()
+	defer stop()
+	batches, polys, err := worker.Run(ctx, workers)
+
+	slog.Info("Solved", "batches", batches, "polynomials", polys)
+	exitIfInterrupted(ctx)
+	if err != nil {
+		fatalf("lost the coordinator: %v", err)
+	}
+}
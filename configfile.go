@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jayalane/algebraic_vis/render"
+)
+
+// A job file sets flags by name, so it can hold anything the command line can. Keys may be
+// grouped into sections ([view], [output], ...) for readability; the grouping is not checked.
+// The positional corners are written as viewport = [x_min, y_min, x_max, y_max].
+
+// configSection groups related settings when a job file is written
+type configSection struct {
+	Name string
+	Keys []string
+}
+
+var configSections = []configSection{
+	{"view", []string{"viewport", "center", "span", "zoom", "preset", "presets", "aspect", "width", "height", "dpi"}},
+	{"enumeration", []string{"max-height", "seed", "family"}},
+	{"coloring", []string{"color-by", "palette"}},
+	{"highlights", []string{"highlight", "small-measure", "highlight-list"}},
+	{"overlays", []string{"overlay", "caption", "caption-pos", "font-size", "text-color", "text-bg"}},
+	{"output", []string{"output", "video", "fps", "tiled", "tile-size", "vector-max-points"}},
+}
+
+// configSkip lists flags that make no sense in a job file
+var configSkip = map[string]bool{"config": true, "dump-config": true, "list-presets": true, "h": true, "help": true,
+	"checkpoint": true, "checkpoint-every": true, "resume": true, "shard": true, "progress": true,
+	"cpuprofile": true, "memprofile": true, "trace": true, "pprof": true,
+	"quiet": true, "q": true, "log-format": true, "workers": true, "memory-limit": true}
+
+// viewKeys choose the viewport; a view given on the command line replaces all of them
+var viewKeys = map[string]bool{"viewport": true, "center": true, "span": true, "zoom": true, "preset": true}
+
+// configSetting is one key = value pair from a job file, with the value in flag syntax
+type configSetting struct {
+	Key, Value string
+}
+
+// readConfigFile parses a .toml, .yaml/.yml or .json job file
+func readConfigFile(path string) ([]configSetting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigData(path, data)
+}
+
+// parseConfigData parses job file contents in the format given by name's extension
+func parseConfigData(path string, data []byte) ([]configSetting, error) {
+	var settings []configSetting
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		settings, err = parseTOML(string(data))
+	case ".yaml", ".yml":
+		settings, err = parseYAML(string(data))
+	case ".json":
+		settings, err = parseJSONConfig(data)
+	default:
+		return nil, fmt.Errorf("unknown job file type %q (want .toml, .yaml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	for i := range settings {
+		settings[i].Key = strings.ReplaceAll(settings[i].Key, "_", "-")
+	}
+	return settings, nil
+}
+
+// applyConfigFile sets each flag named in a job file unless it was given on the command line,
+// and returns the file's viewport corners, if any. viewGiven says the command line chose the
+// view itself, which replaces every view setting in the file.
+func applyConfigFile(path string, flags *flag.FlagSet, viewGiven bool) ([]string, error) {
+	settings, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return applyConfigSettings(path, settings, flags, viewGiven)
+}
+
+// applyConfigSettings is applyConfigFile for settings already read from source
+func applyConfigSettings(path string, settings []configSetting, flags *flag.FlagSet, viewGiven bool) ([]string, error) {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+		if viewKeys[f.Name] {
+			viewGiven = true
+		}
+	})
+
+	var corners []string
+	for _, s := range settings {
+		if given[s.Key] || (viewGiven && viewKeys[s.Key]) {
+			continue
+		}
+		if s.Key == "viewport" {
+			if corners = strings.Split(s.Value, ","); len(corners) != 4 {
+				return nil, fmt.Errorf("%s: viewport wants [x_min, y_min, x_max, y_max]", path)
+			}
+			continue
+		}
+		if flags.Lookup(s.Key) == nil || configSkip[s.Key] {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, s.Key)
+		}
+		if err := flags.Set(s.Key, s.Value); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, s.Key, err)
+		}
+	}
+	return corners, nil
+}
+
+// parseTOML reads the TOML subset used by job files: [section] headers, key = value lines,
+// strings, numbers, booleans, single-line arrays and # comments
+func parseTOML(data string) ([]configSetting, error) {
+	var settings []configSetting
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header", n+1)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", n+1)
+		}
+		v, err := parseConfigValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		settings = append(settings, configSetting{strings.TrimSpace(key), v})
+	}
+	return settings, nil
+}
+
+// parseYAML reads the YAML subset used by job files: key: value lines, optionally nested one
+// level under section keys, flow [a, b] or block "- a" sequences, and # comments
+func parseYAML(data string) ([]configSetting, error) {
+	var settings []configSetting
+	var listKey string // Key awaiting a block sequence (or a section header)
+	var list []string
+	flush := func() {
+		if listKey != "" && len(list) > 0 {
+			settings = append(settings, configSetting{listKey, strings.Join(list, ",")})
+		}
+		listKey, list = "", nil
+	}
+
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" || line == "---" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(line, "-"); ok && (item == "" || item[0] == ' ') {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", n+1)
+			}
+			v, err := parseConfigValue(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n+1, err)
+			}
+			list = append(list, v)
+			continue
+		}
+
+		flush()
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value", n+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value == "" {
+			listKey = key
+			continue
+		}
+		v, err := parseConfigValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		settings = append(settings, configSetting{key, v})
+	}
+	flush()
+	return settings, nil
+}
+
+// parseJSONConfig reads a JSON object of settings, optionally nested one level in sections
+func parseJSONConfig(data []byte) ([]configSetting, error) {
+	var top map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // Keep seeds beyond float64 precision exact
+	if err := dec.Decode(&top); err != nil {
+		return nil, err
+	}
+	var settings []configSetting
+	var add func(m map[string]any) error
+	add = func(m map[string]any) error {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if section, ok := m[k].(map[string]any); ok {
+				if err := add(section); err != nil {
+					return err
+				}
+				continue
+			}
+			v, err := jsonConfigValue(m[k])
+			if err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			settings = append(settings, configSetting{k, v})
+		}
+		return nil
+	}
+	return settings, add(top)
+}
+
+// jsonConfigValue converts a decoded JSON value to flag syntax
+func jsonConfigValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := jsonConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// parseConfigValue converts a TOML or YAML value to flag syntax: quotes are removed and
+// array items are joined with commas
+func parseConfigValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return "", fmt.Errorf("unterminated array")
+		}
+		var items []string
+		for _, item := range splitOutsideQuotes(s[1:len(s)-1], ',') {
+			if item = strings.TrimSpace(item); item == "" {
+				continue // Trailing comma
+			}
+			v, err := parseConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	return s, nil
+}
+
+// stripComment removes a # comment that is not inside a quoted string
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitOutsideQuotes splits s at each sep that is not inside a quoted string
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// writeConfigFile writes the effective settings of a run as a job file, in the format given by
+// the file's extension. The view is written as the final viewport corners.
+func writeConfigFile(path string, flags *flag.FlagSet, config render.Config) error {
+	data, err := formatConfigFile(path, flags, config)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job file: %v", err)
+	}
+	return nil
+}
+
+// formatConfigFile writes a job file in the format given by path's extension
+func formatConfigFile(path string, flags *flag.FlagSet, config render.Config) ([]byte, error) {
+	values := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		if !configSkip[f.Name] && !viewKeys[f.Name] {
+			values[f.Name] = formatConfigValue(f.Value)
+		}
+	})
+	values["width"], values["height"] = strconv.Itoa(config.Width), strconv.Itoa(config.Height) // As set by --tiled
+	values["viewport"] = fmt.Sprintf("[%s, %s, %s, %s]",
+		strconv.FormatFloat(config.XMin, 'g', -1, 64), strconv.FormatFloat(config.YMin, 'g', -1, 64),
+		strconv.FormatFloat(config.XMax, 'g', -1, 64), strconv.FormatFloat(config.YMax, 'g', -1, 64))
+
+	// Settings outside the known sections go first, as TOML requires
+	sections := append([]configSection{{Name: ""}}, configSections...)
+	placed := make(map[string]bool)
+	for _, s := range configSections {
+		for _, k := range s.Keys {
+			placed[k] = true
+		}
+	}
+	for k := range values {
+		if !placed[k] {
+			sections[0].Keys = append(sections[0].Keys, k)
+		}
+	}
+	sort.Strings(sections[0].Keys)
+
+	var b bytes.Buffer
+	format := strings.ToLower(filepath.Ext(path))
+	switch format {
+	case ".toml":
+		for _, s := range sections {
+			if s.Name != "" && b.Len() > 0 {
+				fmt.Fprintf(&b, "\n[%s]\n", s.Name)
+			} else if s.Name != "" {
+				fmt.Fprintf(&b, "[%s]\n", s.Name)
+			}
+			for _, k := range s.Keys {
+				if v, ok := values[k]; ok {
+					fmt.Fprintf(&b, "%s = %s\n", k, v)
+				}
+			}
+		}
+	case ".yaml", ".yml":
+		for _, s := range sections {
+			indent := ""
+			if s.Name != "" {
+				fmt.Fprintf(&b, "%s:\n", s.Name)
+				indent = "  "
+			}
+			for _, k := range s.Keys {
+				if v, ok := values[k]; ok {
+					fmt.Fprintf(&b, "%s%s: %s\n", indent, k, v)
+				}
+			}
+		}
+	case ".json":
+		b.WriteString("{\n")
+		var groups []string
+		for _, s := range sections {
+			var lines []string
+			for _, k := range s.Keys {
+				if v, ok := values[k]; ok {
+					lines = append(lines, fmt.Sprintf("%q: %s", k, v))
+				}
+			}
+			if s.Name == "" {
+				for _, l := range lines {
+					groups = append(groups, "  "+l)
+				}
+			} else if len(lines) > 0 {
+				groups = append(groups, fmt.Sprintf("  %q: {\n    %s\n  }", s.Name, strings.Join(lines, ",\n    ")))
+			}
+		}
+		b.WriteString(strings.Join(groups, ",\n"))
+		b.WriteString("\n}\n")
+	default:
+		return nil, fmt.Errorf("unknown job file type %q (want .toml, .yaml or .json)", filepath.Ext(path))
+	}
+	return b.Bytes(), nil
+}
+
+// formatConfigValue writes a flag's value as a literal that TOML, YAML and JSON all accept
+func formatConfigValue(v flag.Value) string {
+	if g, ok := v.(flag.Getter); ok {
+		switch x := g.Get().(type) {
+		case bool, int, int64:
+			return fmt.Sprint(x)
+		case float64:
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		}
+	}
+	quoted, _ := json.Marshal(v.String())
+	return string(quoted)
+}
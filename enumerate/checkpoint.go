@@ -0,0 +1,239 @@
+package enumerate
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// A checkpoint is two files: FILE holds the enumeration's position and counts as JSON, and
+// FILE.points holds every polynomial solved up to that position (see points.go). The JSON is
+// replaced atomically after the point file is synced, and records past PointBytes are
+// discarded on resume, so a run killed at any moment resumes from its last save.
+
+// CheckpointOptions are the checkpoint flags of a render job
+type CheckpointOptions struct {
+	Path   string        // Empty for no checkpoint
+	Every  time.Duration // Interval between saves
+	Resume bool
+}
+
+// Position locates a polynomial in the enumeration loops: the height, the bit pattern of
+// coefficient magnitudes (for a Family, the ordinal of its halfPattern) and the sign mask
+type Position struct {
+	Height  int `json:"height"`
+	Pattern int `json:"pattern"`
+	Signs   int `json:"signs"`
+}
+
+// Checkpoint is the saved state of an enumeration
+type Checkpoint struct {
+	Seed        int64         `json:"seed"`
+	MaxHeight   int           `json:"max_height"`
+	Family      Family        `json:"family"`
+	Last        *Position     `json:"last"`        // Last polynomial solved; nil before the first
+	Index       int           `json:"index"`       // Index of the polynomial after Last
+	PointBytes  int64         `json:"point_bytes"` // Length of the point file that holds them
+	Polynomials []int         `json:"polynomials"` // Per height, indexed by height
+	Roots       int           `json:"roots"`
+	Elapsed     time.Duration `json:"elapsed_ns"` // Enumeration time over all sessions
+	Saved       time.Time     `json:"saved"`
+}
+
+// Checkpointer saves the progress of one enumeration
+type Checkpointer struct {
+	path   string
+	every  time.Duration
+	state  Checkpoint
+	header PointFileHeader
+	points []Point // Loaded on resume
+	pw     *PointWriter
+	last   time.Time
+}
+
+// pointFilePath returns the point file that goes with a checkpoint
+func pointFilePath(path string) string {
+	return path + ".points"
+}
+
+// ReadCheckpoint loads the state saved at path
+func ReadCheckpoint(path string) (Checkpoint, error) {
+	var c Checkpoint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// OpenCheckpoint starts checkpointing an enumeration to opts.Path, or continues the one saved
+// there with the points it already found. want gives the seed, max height, shard and family of
+// the run.
+func OpenCheckpoint(opts CheckpointOptions, want PointFileHeader) (*Checkpointer, error) {
+	cp := &Checkpointer{path: opts.Path, every: opts.Every, header: want, last: time.Now()}
+	pointPath := pointFilePath(opts.Path)
+
+	if !opts.Resume {
+		if _, err := os.Stat(opts.Path); err == nil {
+			return nil, fmt.Errorf("checkpoint %s already exists; use --resume to continue it or delete it", opts.Path)
+		}
+		pw, err := CreatePointFile(pointPath, want)
+		if err != nil {
+			return nil, err
+		}
+		cp.pw = pw
+		cp.state = Checkpoint{Seed: want.Seed, MaxHeight: want.MaxHeight, Family: want.Family, PointBytes: pw.size}
+		return cp, nil
+	}
+
+	state, err := ReadCheckpoint(opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	if state.Seed != want.Seed {
+		return nil, fmt.Errorf("checkpoint %s was made with seed %d, not %d", opts.Path, state.Seed, want.Seed)
+	}
+	if state.Last != nil && state.Last.Height > want.MaxHeight {
+		return nil, fmt.Errorf("checkpoint %s is already past height %d; use --max-height %d or more",
+			opts.Path, want.MaxHeight, state.Last.Height)
+	}
+	file, err := os.OpenFile(pointPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open point file: %v", err)
+	}
+	fail := func(format string, args ...interface{}) (*Checkpointer, error) {
+		file.Close()
+		return nil, fmt.Errorf(format, args...)
+	}
+
+	// Load the records the checkpoint covers
+	br := bufio.NewReader(io.LimitReader(file, state.PointBytes))
+	header, err := readPointFileHeader(br)
+	if err != nil {
+		return fail("%s: %v", pointPath, err)
+	}
+	if header.v1 {
+		return fail("%s was written by an older version and cannot be resumed; start the checkpoint again", pointPath)
+	}
+	if header.Family != want.Family {
+		return fail("checkpoint %s enumerates %s polynomials, not %s", opts.Path, header.Family, want.Family)
+	}
+	if header.Shard != want.Shard || header.Shards != want.Shards {
+		return fail("checkpoint %s is for shard %d/%d, not %d/%d", opts.Path, header.Shard, header.Shards, want.Shard, want.Shards)
+	}
+	var points []Point
+	n, next := 0, 0
+	for {
+		r, err := readRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail("%s: %v", pointPath, err)
+		}
+		if r.Index < next {
+			return fail("%s: record %d out of order", pointPath, r.Index)
+		}
+		points = append(points, r.points()...)
+		n++
+		next = r.Index + 1
+	}
+	total := 0
+	for _, count := range state.Polynomials {
+		total += count
+	}
+	if n != total || next > state.Index {
+		return fail("%s holds %d polynomials, the checkpoint expects %d", pointPath, n, total)
+	}
+
+	// Drop anything written after the last save
+	if err := file.Truncate(state.PointBytes); err != nil {
+		return fail("failed to truncate point file: %v", err)
+	}
+	if _, err := file.Seek(state.PointBytes, io.SeekStart); err != nil {
+		return fail("failed to seek point file: %v", err)
+	}
+	state.MaxHeight = want.MaxHeight
+	cp.state = state
+	cp.points = points
+	cp.pw = &PointWriter{file: file, w: bufio.NewWriter(file), size: state.PointBytes}
+	return cp, nil
+}
+
+// add appends a solved polynomial, the next in enumeration order, found at pos
+func (cp *Checkpointer) add(r Record, pos Position) error {
+	if err := cp.pw.add(r); err != nil {
+		return err
+	}
+	cp.state.PointBytes = cp.pw.size
+	cp.state.Index = r.Index + 1
+	cp.state.Last = &pos
+	return nil
+}
+
+// due reports whether it is time to save
+func (cp *Checkpointer) due() bool {
+	return time.Since(cp.last) >= cp.every
+}
+
+// save makes the polynomials added so far durable and records the position after them
+func (cp *Checkpointer) save(enum Enumeration, elapsed time.Duration) error {
+	if err := cp.pw.sync(); err != nil {
+		return err
+	}
+
+	cp.state.Polynomials = enum.Polynomials
+	cp.state.Roots = enum.Roots
+	cp.state.Elapsed = elapsed
+	cp.state.Saved = time.Now()
+	data, err := json.MarshalIndent(cp.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp, cp.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	cp.last = time.Now()
+	return nil
+}
+
+// close saves a final time and closes the point file, marking it done
+func (cp *Checkpointer) close(enum Enumeration, elapsed time.Duration) error {
+	if err := cp.save(enum, elapsed); err != nil {
+		cp.pw.file.Close()
+		return err
+	}
+	cp.header.MaxHeight = enum.MaxHeight
+	cp.header.Elapsed = elapsed
+	cp.header.StoppedAt = enum.StoppedAt
+	cp.header.Done = true
+	return cp.pw.finish(cp.header)
+}
+
+// ExportPoints copies the finished point file to path, e.g. as the output of a shard
+func (cp *Checkpointer) ExportPoints(path string) error {
+	in, err := os.Open(pointFilePath(cp.path))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create point file: %v", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write point file: %v", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,219 @@
+package enumerate
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+// Special is a polynomial the classification picked out: Pisot, Salem, cyclotomic, of small
+// Mahler measure, or with a cyclotomic factor
+type Special struct {
+	roots.Classification
+	Coeffs []int
+	Height int
+	Marks  []Mark
+}
+
+// Mark is a point to highlight: the Pisot or Salem number of a polynomial, its roots outside
+// the unit disk for a small measure, or a root of unity of a cyclotomic factor
+type Mark struct {
+	Z    complex128
+	Kind roots.Kind // Cyclotomic for a root of unity
+	H    int        // Lowest height it comes from
+}
+
+// DefaultSmallMeasure is the default bound of roots.SmallMeasure. The smallest measures
+// known lie below it, Lehmer's 1.17628 first.
+const DefaultSmallMeasure = 1.3
+
+// Classify runs roots.Classify over the polynomials of points, each of which must be
+// together in enumeration order, and returns those of interest in that order. A measure
+// below smallBound counts as small. workers goroutines share the polynomials; 0 means one
+// per CPU.
+func Classify(points []Point, smallBound float64, workers int) []Special {
+	// The roots of a polynomial follow one another and share its coefficients
+	var groups [][]Point
+	for i := 0; i < len(points); {
+		j := i + 1
+		for j < len(points) && samePolynomial(points[i], points[j]) {
+			j++
+		}
+		groups = append(groups, points[i:j])
+		i = j
+	}
+
+	found := make([]*Special, len(groups))
+	var wg sync.WaitGroup
+	numWorkers := workers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			zs := make([]complex128, 0, 64)
+			for g := w; g < len(groups); g += numWorkers {
+				zs = zs[:0]
+				for _, p := range groups[g] {
+					zs = append(zs, p.Z)
+				}
+				found[g] = classifyPolynomial(groups[g][0], zs, smallBound)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var specials []Special
+	for _, s := range found {
+		if s != nil {
+			specials = append(specials, *s)
+		}
+	}
+	return specials
+}
+
+// samePolynomial says whether two points are roots of the same polynomial, by the
+// coefficients they share
+func samePolynomial(a, b Point) bool {
+	return len(a.Coeffs) > 0 && len(b.Coeffs) == len(a.Coeffs) && &a.Coeffs[0] == &b.Coeffs[0]
+}
+
+// classifyPolynomial classifies the polynomial of p with roots zs, returning nil if it is
+// of no interest
+func classifyPolynomial(p Point, zs []complex128, smallBound float64) *Special {
+	c := roots.Classify(p.Coeffs, zs, smallBound)
+	if c.Kind == roots.Ordinary && len(c.Orders) == 0 {
+		return nil
+	}
+	s := &Special{Classification: c, Coeffs: p.Coeffs, Height: p.H}
+	switch c.Kind {
+	case roots.Pisot, roots.Salem:
+		s.Marks = append(s.Marks, Mark{Z: complex(c.Number, 0), Kind: c.Kind, H: p.H})
+	case roots.SmallMeasure:
+		for _, z := range zs {
+			if cmplx.Abs(z) > 1+roots.UnitTolerance {
+				s.Marks = append(s.Marks, Mark{Z: z, Kind: c.Kind, H: p.H})
+			}
+		}
+	}
+	for i, m := range c.Orders {
+		if i > 0 && c.Orders[i-1] == m {
+			continue
+		}
+		for _, z := range roots.PrimitiveRoots(m) {
+			s.Marks = append(s.Marks, Mark{Z: z, Kind: roots.Cyclotomic, H: p.H})
+		}
+	}
+	return s
+}
+
+// Marks collects the marks of the kinds chosen, one per kind and place, each at the lowest
+// height it comes from
+func Marks(specials []Special, kinds map[roots.Kind]bool) []Mark {
+	type place struct {
+		kind roots.Kind
+		x, y float64
+	}
+	index := make(map[place]int)
+	var marks []Mark
+	for _, s := range specials {
+		for _, m := range s.Marks {
+			if !kinds[m.Kind] {
+				continue
+			}
+			// Roots found for different polynomials differ in their last digits
+			key := place{m.Kind, math.Round(real(m.Z) * 1e9), math.Round(imag(m.Z) * 1e9)}
+			if i, ok := index[key]; ok {
+				marks[i].H = min(marks[i].H, m.H)
+				continue
+			}
+			index[key] = len(marks)
+			marks = append(marks, m)
+		}
+	}
+	return marks
+}
+
+// MarksUpTo returns the marks that come from heights up to h
+func MarksUpTo(marks []Mark, h int) []Mark {
+	var kept []Mark
+	for _, m := range marks {
+		if m.H <= h {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// Ranked is an entry of the ranked list: a Pisot or Salem number, the non-cyclotomic part of
+// polynomials of small measure, or a cyclotomic polynomial, with the polynomials it came from
+type Ranked struct {
+	Kind       roots.Kind
+	Measure    float64 // Mahler measure: the number itself for Pisot and Salem, 1 for cyclotomic
+	Number     float64 // Pisot or Salem number; 0 otherwise
+	Factor     []int   // Minimal polynomial, or the non-cyclotomic part for a small measure, constant term first
+	Polynomial []int   // The first polynomial with it, of the lowest height
+	Height     int     // Height of Polynomial
+	Count      int     // Polynomials with it
+}
+
+// Rank lists the Pisot and Salem numbers, small measures and cyclotomic factors of the
+// specials once each: by Mahler measure, then kind, degree and coefficients, with the
+// cyclotomic factors last. Kinds not in kinds are left out.
+func Rank(specials []Special, kinds map[roots.Kind]bool) []Ranked {
+	index := make(map[string]int)
+	var list []Ranked
+	add := func(r Ranked) {
+		key := fmt.Sprint(r.Kind, r.Factor)
+		if i, ok := index[key]; ok {
+			list[i].Count++
+			return
+		}
+		index[key] = len(list)
+		list = append(list, r)
+	}
+	for _, s := range specials {
+		switch s.Kind {
+		case roots.Pisot, roots.Salem, roots.SmallMeasure:
+			if kinds[s.Kind] {
+				add(Ranked{Kind: s.Kind, Measure: s.Measure, Number: s.Number, Factor: s.Factor,
+					Polynomial: s.Coeffs, Height: s.Height, Count: 1})
+			}
+		}
+		if kinds[roots.Cyclotomic] {
+			for i, m := range s.Orders {
+				if i == 0 || s.Orders[i-1] != m {
+					add(Ranked{Kind: roots.Cyclotomic, Measure: 1, Factor: roots.CyclotomicPolynomial(m),
+						Polynomial: s.Coeffs, Height: s.Height, Count: 1})
+				}
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case (a.Kind == roots.Cyclotomic) != (b.Kind == roots.Cyclotomic):
+			return b.Kind == roots.Cyclotomic
+		case math.Abs(a.Measure-b.Measure) > 1e-9*a.Measure: // The same measure from different roots
+			return a.Measure < b.Measure
+		case a.Kind != b.Kind:
+			return a.Kind < b.Kind
+		case len(a.Factor) != len(b.Factor):
+			return len(a.Factor) < len(b.Factor)
+		}
+		for k := len(a.Factor) - 1; k >= 0; k-- {
+			if a.Factor[k] != b.Factor[k] {
+				return a.Factor[k] < b.Factor[k]
+			}
+		}
+		return false
+	})
+	return list
+}
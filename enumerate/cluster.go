@@ -0,0 +1,514 @@
+package enumerate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/rpc"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/internal/buildinfo"
+	"github.com/jayalane/algebraic_vis/internal/logging"
+)
+
+// A coordinator enumerates the polynomials and hands them out in batches of consecutive
+// indexes to worker processes over net/rpc. A batch is leased to one worker; it goes back
+// in the queue if that worker disconnects or holds it past the lease. The first result
+// submitted for a batch is kept, and the batches are put back together in index order.
+// Roots depend only on the seed and the coefficients, so the output matches a single run.
+//
+// WorkerHello, RunParams, BatchPoly, WorkBatch and BatchResult are the messages between
+// them; net/rpc only carries exported types.
+
+// WorkerHello introduces a worker to the coordinator
+type WorkerHello struct {
+	Name    string
+	Version string // Workers must run the same build as the coordinator
+}
+
+// RunParams tells a worker how to solve
+type RunParams struct {
+	Seed int64
+}
+
+// BatchPoly is one polynomial of a batch
+type BatchPoly struct {
+	Index, Height int
+	Coeffs        []int // Constant term first
+}
+
+// WorkBatch is the coordinator's answer to a request for work
+type WorkBatch struct {
+	ID    int
+	Polys []BatchPoly
+	Done  bool // The enumeration is over
+}
+
+// BatchResult carries a solved batch back
+type BatchResult struct {
+	ID      int
+	Records []Record
+}
+
+// workBatch is a batch as the coordinator tracks it
+type workBatch struct {
+	WorkBatch
+	holder  int // Session that leased it
+	expires time.Time
+}
+
+// coordinator hands out the batches of one enumeration
+type coordinator struct {
+	seed    int64
+	lease   time.Duration
+	fresh   chan *workBatch // From the generator, closed after the last
+	version string
+
+	mu       sync.Mutex
+	heights  []int // First height of each batch generated
+	requeued []*workBatch
+	leased   map[int]*workBatch
+	solved   map[int][]Record
+	total    int // Batches generated; -1 until the generator is done
+	stopped  bool
+	finished chan struct{} // Closed once every batch is solved
+	names    map[int]string
+	progress *progressTracker
+	log      *slog.Logger
+}
+
+// generate splits the enumeration into batches until it ends or stop is closed
+func (c *coordinator) generate(maxHeight int, family Family, batchSize int, stop <-chan struct{}) {
+	defer close(c.fresh)
+	id := 0
+	stopped := false
+	var batch []BatchPoly
+	send := func() bool {
+		c.mu.Lock()
+		c.heights = append(c.heights, batch[0].Height)
+		c.mu.Unlock()
+		select {
+		case c.fresh <- &workBatch{WorkBatch: WorkBatch{ID: id, Polys: batch}}:
+			id++
+			batch = nil
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	family.Polynomials(maxHeight, func(work Work) bool {
+		coeffs := make([]int, len(work.Coeffs))
+		for i, z := range work.Coeffs {
+			coeffs[i] = int(real(z))
+		}
+		batch = append(batch, BatchPoly{Index: work.Index, Height: work.H, Coeffs: coeffs})
+		if len(batch) < batchSize {
+			return true
+		}
+		stopped = !send()
+		return !stopped
+	})
+	if len(batch) > 0 && !stopped {
+		stopped = !send()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stopped {
+		return
+	}
+	c.total = id
+	c.checkFinished()
+}
+
+// checkFinished closes finished once every batch is solved; c.mu must be held
+func (c *coordinator) checkFinished() {
+	if c.total >= 0 && len(c.solved) == c.total {
+		select {
+		case <-c.finished:
+		default:
+			close(c.finished)
+		}
+	}
+}
+
+// requeue puts back the batches session holds, or all expired ones if session is -1;
+// c.mu must be held
+func (c *coordinator) requeue(session int, now time.Time) int {
+	n := 0
+	for id, b := range c.leased {
+		if b.holder == session || (session < 0 && now.After(b.expires)) {
+			delete(c.leased, id)
+			c.requeued = append(c.requeued, b)
+			n++
+		}
+	}
+	// Hand out the oldest first, so the output fills in from the start
+	sort.Slice(c.requeued, func(i, j int) bool { return c.requeued[i].ID < c.requeued[j].ID })
+	return n
+}
+
+// next leases a batch to session. Once every batch is out it waits for one to come back
+// or for the enumeration to end.
+func (c *coordinator) next(session int) WorkBatch {
+	for {
+		c.mu.Lock()
+		if c.stopped || (c.total >= 0 && len(c.solved) == c.total) {
+			c.mu.Unlock()
+			return WorkBatch{Done: true}
+		}
+		if n := c.requeue(-1, time.Now()); n > 0 {
+			c.log.Warn("Requeued batches whose lease expired", "batches", n)
+		}
+		var b *workBatch
+		if len(c.requeued) > 0 {
+			b, c.requeued = c.requeued[0], c.requeued[1:]
+		}
+		c.mu.Unlock()
+
+		if b == nil {
+			var ok bool
+			if b, ok = <-c.fresh; !ok {
+				select {
+				case <-c.finished:
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+		}
+
+		c.mu.Lock()
+		b.holder = session
+		b.expires = time.Now().Add(c.lease)
+		c.leased[b.ID] = b
+		c.mu.Unlock()
+		return b.WorkBatch
+	}
+}
+
+// submit keeps the first result for each batch and says whether every batch is solved. A
+// result for a batch that is not out, or that does not match the batch, is an error.
+func (c *coordinator) submit(result BatchResult) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.solved[result.ID]; ok {
+		return c.stopped || len(c.solved) == c.total, nil // A late copy of a batch handed out again
+	}
+	b, requeued := c.leased[result.ID], -1
+	for i, q := range c.requeued {
+		if q.ID == result.ID {
+			b, requeued = q, i
+			break
+		}
+	}
+	if b == nil {
+		return false, fmt.Errorf("batch %d was not handed out", result.ID)
+	}
+	if err := checkResult(b.WorkBatch, result); err != nil {
+		return false, err
+	}
+	delete(c.leased, result.ID)
+	if requeued >= 0 {
+		c.requeued = append(c.requeued[:requeued], c.requeued[requeued+1:]...)
+	}
+	c.solved[result.ID] = result.Records
+	roots := 0
+	for _, r := range result.Records {
+		roots += len(r.Roots)
+	}
+	c.progress.add(-1, len(result.Records), roots) // Batches finish out of order
+	c.checkFinished()
+	return c.stopped || len(c.solved) == c.total, nil
+}
+
+// checkResult checks that result holds a record for each polynomial of batch, in order,
+// with no more roots than its degree
+func checkResult(batch WorkBatch, result BatchResult) error {
+	if len(result.Records) != len(batch.Polys) {
+		return fmt.Errorf("batch %d has %d polynomials, not %d", batch.ID, len(batch.Polys), len(result.Records))
+	}
+	for i, r := range result.Records {
+		p := batch.Polys[i]
+		same := r.Index == p.Index && r.Height == p.Height && len(r.Coeffs) == len(p.Coeffs)
+		for j := 0; same && j < len(p.Coeffs); j++ {
+			same = r.Coeffs[j] == p.Coeffs[j]
+		}
+		if !same {
+			return fmt.Errorf("batch %d: record %d is not polynomial %d of height %d", batch.ID, i, p.Index, p.Height)
+		}
+		if len(r.Roots) > len(p.Coeffs)-1 || len(r.Mult) != len(r.Roots) {
+			return fmt.Errorf("batch %d: polynomial %d has %d roots and %d multiplicities at degree %d",
+				batch.ID, p.Index, len(r.Roots), len(r.Mult), len(p.Coeffs)-1)
+		}
+	}
+	return nil
+}
+
+// workerSession is the RPC service for one worker connection
+type workerSession struct {
+	c  *coordinator
+	id int
+}
+
+// Hello checks the worker's build and returns the run parameters
+func (s *workerSession) Hello(hello WorkerHello, params *RunParams) error {
+	if hello.Version != s.c.version {
+		return fmt.Errorf("worker runs %s, the coordinator %s; both need the same build", hello.Version, s.c.version)
+	}
+	s.c.mu.Lock()
+	s.c.names[s.id] = hello.Name
+	s.c.mu.Unlock()
+	s.c.log.Info("Worker connected", "worker", hello.Name)
+	*params = RunParams{Seed: s.c.seed}
+	return nil
+}
+
+// Next leases the worker a batch
+func (s *workerSession) Next(_ struct{}, batch *WorkBatch) error {
+	*batch = s.c.next(s.id)
+	return nil
+}
+
+// Submit returns a solved batch, and tells the worker whether the enumeration is done, so
+// that the one solving the last batch need not ask a coordinator that may have exited
+func (s *workerSession) Submit(result BatchResult, done *bool) error {
+	var err error
+	if *done, err = s.c.submit(result); err != nil {
+		s.c.mu.Lock()
+		name := s.c.names[s.id]
+		s.c.mu.Unlock()
+		s.c.log.Warn("Rejected a result", "worker", name, "error", err)
+	}
+	return err
+}
+
+// Defaults of Options.BatchSize and Options.Lease
+const (
+	DefaultBatchSize = 1000
+	DefaultLease     = 2 * time.Minute
+)
+
+// workerGrace bounds how long Coordinate waits for its workers to leave once it is done
+const workerGrace = time.Second
+
+// Coordinate serves the enumeration to workers on ln until every batch is solved or ctx is
+// cancelled, and returns the points as Run would. It cannot shard, checkpoint or write
+// its enumeration, and each worker sets its own goroutines in Worker.Run.
+func Coordinate(ctx context.Context, ln net.Listener, maxHeight int, seed int64, opts Options) ([]Point, Enumeration, error) {
+	if opts.Shards > 1 || opts.Checkpoint != nil || opts.Output != nil {
+		return nil, Enumeration{}, fmt.Errorf("a coordinated enumeration cannot be sharded, checkpointed or written out")
+	}
+	if opts.Workers != 0 {
+		return nil, Enumeration{}, fmt.Errorf("a coordinated enumeration is solved by its workers' goroutines; Workers does not apply")
+	}
+	family, batchSize, lease := opts.Family, opts.BatchSize, opts.Lease
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	start := time.Now()
+	c := &coordinator{
+		seed:     seed,
+		lease:    lease,
+		fresh:    make(chan *workBatch, 4),
+		version:  buildinfo.Version(),
+		leased:   make(map[int]*workBatch),
+		solved:   make(map[int][]Record),
+		total:    -1,
+		finished: make(chan struct{}),
+		names:    make(map[int]string),
+		progress: newProgressTracker(opts.Progress, family.heightCount, maxHeight, 0, 0),
+		log:      logging.Or(opts.Logger),
+	}
+	stop := make(chan struct{})
+	generated := make(chan struct{})
+	go func() {
+		c.generate(maxHeight, family, batchSize, stop)
+		close(generated)
+	}()
+
+	// Each connection gets its own service, so that a closed one returns its batches
+	var sessions sync.WaitGroup
+	accepting := make(chan struct{})
+	go func() {
+		defer close(accepting)
+		for session := 0; ; session++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // Closed
+			}
+			server := rpc.NewServer()
+			server.RegisterName("Coordinator", &workerSession{c: c, id: session})
+			sessions.Add(1)
+			go func(session int) {
+				defer sessions.Done()
+				server.ServeConn(conn)
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				if n := c.requeue(session, time.Now()); c.names[session] != "" && !c.stopped {
+					c.log.Warn("Worker disconnected", "worker", c.names[session], "requeued", n)
+				}
+			}(session)
+		}
+	}()
+	c.log.Info("Waiting for workers", "address", ln.Addr().String())
+
+	select {
+	case <-c.finished:
+	case <-ctx.Done():
+	}
+	c.mu.Lock()
+	c.stopped = true // Workers asking for more are told to finish
+	c.mu.Unlock()
+	close(stop)
+	ln.Close()
+
+	// Give the workers waiting for a batch a moment to hear that there are no more before
+	// returning, as the caller may exit
+	<-accepting
+	left := make(chan struct{})
+	go func() {
+		sessions.Wait()
+		close(left)
+	}()
+	select {
+	case <-left:
+	case <-time.After(workerGrace):
+	}
+
+	for range c.fresh {
+		// Unblock the generator
+	}
+	<-generated
+
+	// The first unsolved batch marks where an interrupted run stopped
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stoppedAt := 0
+	for id, h := range c.heights {
+		if _, ok := c.solved[id]; !ok && (stoppedAt == 0 || h < stoppedAt) {
+			stoppedAt = h
+		}
+	}
+	ids := make([]int, 0, len(c.solved))
+	for id := range c.solved {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	enum := Enumeration{MaxHeight: maxHeight, Seed: seed, Family: family, Polynomials: make([]int, maxHeight+1), StoppedAt: stoppedAt}
+	var points []Point
+	for _, id := range ids {
+		for _, r := range c.solved[id] {
+			points = append(points, r.points()...)
+			enum.Polynomials[r.Height]++
+			enum.Roots += len(r.Roots)
+		}
+	}
+	enum.Duration = time.Since(start)
+	c.progress.finish()
+	logDone(c.log, "Generated", enum)
+	return points, enum, nil
+}
+
+// batchWork turns a polynomial of a batch back into work for Solve
+func batchWork(p BatchPoly) Work {
+	coeffs := make([]complex128, len(p.Coeffs))
+	for i, c := range p.Coeffs {
+		coeffs[i] = complex(float64(c), 0)
+	}
+	order := len(p.Coeffs) - 1
+	return Work{
+		Index:        p.Index,
+		Coeffs:       coeffs,
+		H:            p.Height,
+		Order:        order,
+		LeadingCoeff: p.Coeffs[order],
+	}
+}
+
+// Worker solves batches for a coordinator
+type Worker struct {
+	client *rpc.Client
+	seed   int64
+}
+
+// NewWorker introduces a worker named name to the coordinator at the other end of conn
+func NewWorker(conn io.ReadWriteCloser, name string) (*Worker, error) {
+	client := rpc.NewClient(conn)
+	var params RunParams
+	if err := client.Call("Coordinator.Hello", WorkerHello{Name: name, Version: buildinfo.Version()}, &params); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Worker{client: client, seed: params.Seed}, nil
+}
+
+// Run solves batches on workers goroutines until the coordinator is done or ctx is
+// cancelled, and returns how many batches and polynomials it solved. A cancelled worker
+// just leaves; the coordinator hands its batches to others.
+func (w *Worker) Run(ctx context.Context, workers int) (batches, polys int, err error) {
+	var mu sync.Mutex
+	var done atomic.Bool // The coordinator may exit as soon as one goroutine hears it is done
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(w.seed))
+			for ctx.Err() == nil {
+				var batch WorkBatch
+				if err := w.client.Call("Coordinator.Next", struct{}{}, &batch); err != nil {
+					if !done.Load() {
+						errs <- err
+					}
+					return
+				}
+				if batch.Done {
+					done.Store(true)
+					return
+				}
+
+				result := BatchResult{ID: batch.ID, Records: make([]Record, len(batch.Polys))}
+				for j, p := range batch.Polys {
+					result.Records[j] = Solve(batchWork(p), w.seed, rng)
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				var finished bool
+				if err := w.client.Call("Coordinator.Submit", result, &finished); err != nil {
+					if !done.Load() {
+						errs <- err
+					}
+					return
+				}
+				mu.Lock()
+				batches++
+				polys += len(batch.Polys)
+				mu.Unlock()
+				if finished {
+					done.Store(true)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return batches, polys, <-errs
+}
+
+// Close closes the connection to the coordinator
+func (w *Worker) Close() error {
+	return w.client.Close()
+}
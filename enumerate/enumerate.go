@@ -0,0 +1,493 @@
+// Package enumerate lists the integer polynomials of each height and solves them in
+// parallel, producing the algebraic numbers as Points. A seed makes a run reproducible:
+// the roots of a polynomial depend only on the seed and its coefficients, so an
+// enumeration can be checkpointed, split into shards or handed to remote workers and
+// still come out the same as a single run.
+package enumerate
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/internal/logging"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+// Point represents an algebraic number with its properties
+type Point struct {
+	Z            complex128 // The complex number
+	H            int        // Height (complexity measure)
+	O            int        // Order (degree of polynomial)
+	LeadingCoeff int        // Leading coefficient of the polynomial
+	Disc         float64    // |Discriminant| of the polynomial
+	Mahler       float64    // Mahler measure of the polynomial
+	Mult         int        // Multiplicity of this root
+	Coeffs       []int      // Polynomial coefficients, constant term first; shared by its roots
+}
+
+// Work represents work for processing a single polynomial
+type Work struct {
+	Index        int      // Position in enumeration order
+	Seq          int      // Position among the polynomials this run solves
+	Pos          Position // Where the enumeration loops were, for checkpoints
+	Coeffs       []complex128
+	H            int
+	Order        int
+	LeadingCoeff int
+}
+
+// Enumeration describes a run of Generate
+type Enumeration struct {
+	MaxHeight   int
+	Seed        int64
+	Family      Family // Polynomials enumerated
+	Polynomials []int  // Polynomials solved at each height, indexed by height
+	Roots       int
+	Duration    time.Duration
+	StoppedAt   int // Height an interrupted enumeration stopped during; the heights below are complete. 0 if it finished.
+}
+
+// UpTo describes the part of the enumeration up to height h, given the points it produced
+func (e Enumeration) UpTo(h int, points []Point) Enumeration {
+	if h < e.MaxHeight {
+		e.MaxHeight = h
+		e.Polynomials = e.Polynomials[:h+1]
+		e.Roots = len(points)
+	}
+	if e.StoppedAt > h {
+		e.StoppedAt = 0
+	}
+	return e
+}
+
+// PolynomialCount is the number of polynomials solved
+func (e Enumeration) PolynomialCount() int {
+	n := 0
+	for _, c := range e.Polynomials {
+		n += c
+	}
+	return n
+}
+
+// DescribeStop says where a run interrupted during height h stopped
+func DescribeStop(h int) string {
+	if h <= 2 {
+		return fmt.Sprintf("interrupted during height %d; no height is complete", h)
+	}
+	return fmt.Sprintf("interrupted during height %d; heights 2-%d are complete", h, h-1)
+}
+
+// logDone logs the outcome of an enumeration under msg
+func logDone(log *slog.Logger, msg string, enum Enumeration) {
+	if enum.StoppedAt > 0 {
+		log.Warn("Enumeration "+DescribeStop(enum.StoppedAt), "stopped_at", enum.StoppedAt)
+	}
+	log.Info(msg, "polynomials", enum.PolynomialCount(), "roots", enum.Roots, "duration", enum.Duration.Round(time.Millisecond))
+}
+
+// NewSeed picks a seed for a run that was not given one
+func NewSeed() int64 {
+	if seed := time.Now().UnixNano(); seed != 0 {
+		return seed
+	}
+	return 1
+}
+
+// polySeed derives the random seed for one polynomial from the run's seed, so that its roots
+// come out the same whichever worker solves it and however far the enumeration goes
+func polySeed(seed int64, coeffs []complex128) int64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	h.Write(buf[:])
+	for _, c := range coeffs {
+		binary.LittleEndian.PutUint64(buf[:], uint64(int64(real(c))))
+		h.Write(buf[:])
+	}
+	return int64(h.Sum64())
+}
+
+// Generate computes algebraic numbers up to given height using parallel processing.
+// The points come out in enumeration order, and a given seed always finds the same roots;
+// seed 0 picks one, which is returned in the Enumeration. When ctx is cancelled no more
+// polynomials are started, and the points of those already solved are returned.
+func Generate(ctx context.Context, maxHeight int, seed int64) ([]Point, Enumeration) {
+	points, enum, _ := Run(ctx, maxHeight, seed, Options{}) // Only checkpoints and outputs fail
+	return points, enum
+}
+
+// Options are the optional parts of an enumeration. Coordinate takes Progress, Family,
+// BatchSize, Lease and Logger, and rejects the others.
+type Options struct {
+	Shard, Shards int            // Solve only shard Shard (1-based) of Shards, if there are several
+	Checkpoint    *Checkpointer  // Continue from and save to
+	Output        *PointWriter   // Also write the polynomials solved here, in order
+	Progress      func(Progress) // Called every second and once at the end, if not nil
+	Workers       int            // Goroutines solving polynomials; 0 means one per CPU
+	Family        Family         // Enumerate only the polynomials of this family
+	BatchSize     int            // Polynomials per batch handed to a worker by Coordinate; 0 means DefaultBatchSize
+	Lease         time.Duration  // Time a worker may hold a batch before Coordinate requeues it; 0 means DefaultLease
+	Logger        *slog.Logger   // Where progress is logged; nil logs nothing
+}
+
+// Work is handed to the solving goroutines in batches of consecutive polynomials, to keep
+// the channel traffic small next to the solving. A batch ends when its polynomials' squared
+// degrees add up to batchCost, which solving takes roughly in proportion to, so that low
+// degrees go many to a batch and high ones a few.
+const (
+	batchCost   = 1024
+	maxBatchLen = 256
+)
+
+// shardOf assigns the coefficient pattern i of height h to one of n shards. Neighbouring
+// patterns go to different shards, which spreads the work of each height evenly.
+func shardOf(h, i, n int) int {
+	return (h + i/2) % n
+}
+
+// Solve finds the roots of one polynomial. rng is reseeded from the run's seed and the
+// coefficients, so that any worker in any process finds the same roots.
+func Solve(work Work, seed int64, rng *rand.Rand) Record {
+	rng.Seed(polySeed(seed, work.Coeffs))
+	zs := roots.Find(work.Coeffs, work.Order, rng)
+	disc, mahler := roots.Invariants(zs, work.LeadingCoeff)
+	record := Record{
+		Index:  work.Index,
+		Height: work.H,
+		Coeffs: make([]int, len(work.Coeffs)),
+		Disc:   disc,
+		Mahler: mahler,
+		Roots:  zs,
+		Mult:   make([]int, len(zs)),
+	}
+	for i, c := range work.Coeffs {
+		record.Coeffs[i] = int(real(c))
+	}
+	for i := range zs {
+		record.Mult[i] = roots.Multiplicity(zs, i)
+	}
+	return record
+}
+
+// Polynomials passes the polynomials of heights up to maxHeight to yield in enumeration
+// order, until yield returns false
+func Polynomials(maxHeight int, yield func(Work) bool) {
+	polynomials(maxHeight, span{}, yield)
+}
+
+// span is the part of an enumeration a run goes through
+type span struct {
+	after         *Position // Start past this polynomial, whose index is firstIndex-1; nil starts at the beginning
+	firstIndex    int
+	shard, shards int // Only the patterns of shard (1-based) of shards, if there are several
+}
+
+// polynomials is Polynomials for the span s. It skips the patterns of other shards, but
+// counts their polynomials.
+func polynomials(maxHeight int, s span, yield func(Work) bool) {
+	after, shard, shards := s.after, s.shard, s.shards
+	index := s.firstIndex
+	seq := 0
+	firstHeight := 2
+	if after != nil {
+		firstHeight = after.Height
+	}
+	for h := firstHeight; h <= maxHeight; h++ {
+		// Generate all possible coefficient patterns for height h
+		firstPattern := (1 << (h - 1)) - 1
+		if after != nil && h == after.Height {
+			firstPattern = after.Pattern
+		}
+		for i := firstPattern; i >= 0; i -= 2 { // Step by 2 to avoid leading coefficient 0
+			// Convert bit pattern to coefficient magnitudes
+			coeffMags := make([]int, h)
+			k := 0
+			for j := h - 2; j >= 0; j-- {
+				if (i>>j)&1 == 1 {
+					coeffMags[k]++
+				} else {
+					k++
+					if k < h {
+						coeffMags[k] = 0
+					}
+				}
+			}
+
+			if k == 0 {
+				continue // Invalid polynomial
+			}
+
+			order := k
+
+			// Count non-zero coefficients for sign combinations
+			nonZero := 0
+			for j := 0; j <= order; j++ {
+				if coeffMags[j] != 0 {
+					nonZero++
+				}
+			}
+
+			// Leave other shards' patterns to them, but count their polynomials
+			if shards > 1 && shardOf(h, i, shards) != shard-1 {
+				index += 1 << (nonZero - 1)
+				continue
+			}
+
+			// Generate all sign combinations
+			firstSigns := 0
+			if after != nil && h == after.Height && i == after.Pattern {
+				firstSigns = after.Signs + 1
+			}
+			for signs := firstSigns; signs < (1 << (nonZero - 1)); signs++ {
+				// Build coefficient array
+				coeffs := make([]complex128, order+1)
+				signBit := 0
+
+				for j := 0; j <= order; j++ {
+					if coeffMags[j] == 0 || j == order {
+						coeffs[j] = complex(float64(coeffMags[j]), 0)
+					} else {
+						sign := 1
+						if (signs>>signBit)&1 == 1 {
+							sign = -1
+						}
+						coeffs[j] = complex(float64(sign*coeffMags[j]), 0)
+						signBit++
+					}
+				}
+
+				work := Work{
+					Index:        index,
+					Seq:          seq,
+					Pos:          Position{Height: h, Pattern: i, Signs: signs},
+					Coeffs:       coeffs,
+					H:            h,
+					Order:        order,
+					LeadingCoeff: coeffMags[order],
+				}
+				if !yield(work) {
+					return
+				}
+				index++
+				seq++
+			}
+		}
+	}
+}
+
+// Run is Generate with options; the Enumeration counts only the
+// shard's polynomials, but indexes them as in a full run
+func Run(ctx context.Context, maxHeight int, seed int64, opts Options) ([]Point, Enumeration, error) {
+	start := time.Now()
+	if seed == 0 {
+		seed = NewSeed()
+	}
+	numWorkers := opts.Workers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	log := logging.Or(opts.Logger)
+	log.Info("Enumerating", "max_height", maxHeight, "family", opts.Family, "workers", numWorkers)
+
+	// Pick up where a checkpoint left off
+	enum := Enumeration{MaxHeight: maxHeight, Seed: seed, Family: opts.Family, Polynomials: make([]int, maxHeight+1)}
+	var allPoints []Point
+	var after *Position // Last polynomial already done
+	firstIndex := 0
+	var earlier time.Duration
+	cp := opts.Checkpoint
+	if cp != nil {
+		allPoints = cp.points
+		after = cp.state.Last
+		firstIndex = cp.state.Index
+		copy(enum.Polynomials, cp.state.Polynomials)
+		enum.Roots = cp.state.Roots
+		earlier = cp.state.Elapsed
+		if after != nil {
+			log.Info("Resuming", "checkpoint", cp.path, "polynomials", enum.PolynomialCount(), "roots", enum.Roots)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx) // Also stops the run when a checkpoint or output cannot be written
+	defer cancel()
+	progress := newProgressTracker(opts.Progress, opts.Family.heightCount, maxHeight, firstIndex, enum.Roots)
+
+	// Work goes out and comes back in batches of consecutive polynomials, a few queued
+	// per worker
+	type polyResult struct {
+		record Record
+		pos    Position
+	}
+	type resultBatch struct {
+		seq     int // Of the first
+		results []polyResult
+	}
+	workCh := make(chan []Work, 2*numWorkers)
+	resultCh := make(chan resultBatch, 2*numWorkers)
+
+	// Lowest height with a polynomial left unsolved by an interrupt
+	var stopMu sync.Mutex
+	stoppedAt := 0
+	stop := func(h int) {
+		stopMu.Lock()
+		if stoppedAt == 0 || h < stoppedAt {
+			stoppedAt = h
+		}
+		stopMu.Unlock()
+	}
+
+	// Start workers
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker gets its own random source for thread safety
+			localRand := rand.New(rand.NewSource(seed))
+
+			for batch := range workCh {
+				if ctx.Err() != nil {
+					stop(batch[0].H) // Drain the queue without solving
+					continue
+				}
+				results := make([]polyResult, len(batch))
+				for i, work := range batch {
+					results[i] = polyResult{Solve(work, seed, localRand), work.Pos}
+				}
+				resultCh <- resultBatch{batch[0].Seq, results}
+			}
+		}()
+	}
+
+	// Generate batches of work, until interrupted
+	go func() {
+		defer close(workCh)
+		var batch []Work
+		cost := 0
+		send := func() bool {
+			select {
+			case workCh <- batch:
+				batch, cost = nil, 0
+				return true
+			case <-ctx.Done():
+				stop(batch[0].H)
+				return false
+			}
+		}
+		stopped := false
+		opts.Family.polynomials(maxHeight, span{after: after, firstIndex: firstIndex, shard: opts.Shard, shards: opts.Shards}, func(work Work) bool {
+			batch = append(batch, work)
+			cost += work.Order * work.Order
+			if cost < batchCost && len(batch) < maxBatchLen {
+				return true
+			}
+			stopped = !send()
+			return !stopped
+		})
+		if len(batch) > 0 && !stopped {
+			send()
+		}
+	}()
+
+	// Collect results
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	// Put the results back in enumeration order; checkpoint and output get the unbroken prefix
+	pending := make(map[int]resultBatch)
+	next := 0
+	var cpErr error
+	for batch := range resultCh {
+		pending[batch.seq] = batch
+		for {
+			b, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next += len(b.results)
+			roots := 0
+			for _, r := range b.results {
+				allPoints = append(allPoints, r.record.points()...)
+				enum.Polynomials[r.record.Height]++
+				roots += len(r.record.Roots)
+				if cp != nil && cpErr == nil {
+					cpErr = cp.add(r.record, r.pos)
+				}
+				if opts.Output != nil && cpErr == nil {
+					cpErr = opts.Output.add(r.record)
+				}
+			}
+			enum.Roots += roots
+			progress.add(b.results[len(b.results)-1].record.Index, len(b.results), roots)
+		}
+		if cp != nil && cpErr == nil && cp.due() {
+			cpErr = cp.save(enum, earlier+time.Since(start))
+		}
+		if cpErr != nil {
+			cancel()
+		}
+	}
+
+	enum.StoppedAt = stoppedAt
+	if cp != nil {
+		if cpErr == nil {
+			cpErr = cp.close(enum, earlier+time.Since(start))
+		}
+		if cpErr != nil {
+			return nil, enum, fmt.Errorf("checkpoint: %v", cpErr)
+		}
+	}
+	if opts.Output != nil {
+		header := PointFileHeader{Seed: seed, Elapsed: earlier + time.Since(start), MaxHeight: maxHeight,
+			Shard: opts.Shard, Shards: opts.Shards, StoppedAt: stoppedAt, Done: true, Family: opts.Family}
+		if cpErr == nil {
+			cpErr = opts.Output.finish(header)
+		}
+		if cpErr != nil {
+			return nil, enum, cpErr
+		}
+	}
+
+	// An interrupt can leave gaps; keep what was solved past them, in order
+	if len(pending) > 0 {
+		rest := make([]int, 0, len(pending))
+		for seq := range pending {
+			rest = append(rest, seq)
+		}
+		sort.Ints(rest)
+		for _, seq := range rest {
+			for _, r := range pending[seq].results {
+				allPoints = append(allPoints, r.record.points()...)
+				enum.Polynomials[r.record.Height]++
+				enum.Roots += len(r.record.Roots)
+			}
+		}
+	}
+	enum.Duration = earlier + time.Since(start)
+	progress.finish()
+
+	logDone(log, "Generated", enum)
+	return allPoints, enum, nil
+}
+
+// SortByHeight returns a copy of points ordered by height, so that every height has a prefix
+func SortByHeight(points []Point) []Point {
+	sorted := append([]Point(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].H < sorted[j].H })
+	return sorted
+}
+
+// UpToHeight returns the prefix of height-sorted points with height at most h
+func UpToHeight(sorted []Point, h int) []Point {
+	return sorted[:sort.Search(len(sorted), func(i int) bool { return sorted[i].H > h })]
+}
@@ -0,0 +1,632 @@
+package enumerate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+// bruteForce lists every integer polynomial of height h, as the enumeration defines it:
+// degree n >= 1, positive leading coefficient and n + 1 + sum |c_i| = h
+func bruteForce(h int) []string {
+	var polys []string
+	for n := 1; n+2 <= h; n++ {
+		coeffs := make([]int, n+1)
+		var fill func(i, left int)
+		fill = func(i, left int) {
+			if i == n {
+				if left > 0 {
+					coeffs[n] = left
+					polys = append(polys, fmt.Sprint(coeffs))
+				}
+				return
+			}
+			for c := -left; c <= left; c++ {
+				coeffs[i] = c
+				fill(i+1, left-abs(c))
+			}
+		}
+		fill(0, h-1-n)
+	}
+	sort.Strings(polys)
+	return polys
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// intCoeffs returns the coefficients of a work item as integers
+func intCoeffs(w Work) []int {
+	coeffs := make([]int, len(w.Coeffs))
+	for i, c := range w.Coeffs {
+		coeffs[i] = int(real(c))
+	}
+	return coeffs
+}
+
+// collect runs polynomials over the span and returns the work items it yields
+func collect(maxHeight int, s span) []Work {
+	var works []Work
+	polynomials(maxHeight, s, func(w Work) bool {
+		works = append(works, w)
+		return true
+	})
+	return works
+}
+
+func TestPolynomialsMatchBruteForce(t *testing.T) {
+	const maxHeight = 12
+	byHeight := make(map[int][]string)
+	for _, w := range collect(maxHeight, span{}) {
+		coeffs := intCoeffs(w)
+		if len(coeffs) != w.Order+1 || coeffs[w.Order] != w.LeadingCoeff {
+			t.Fatalf("%v: order %d, leading coefficient %d", coeffs, w.Order, w.LeadingCoeff)
+		}
+		byHeight[w.H] = append(byHeight[w.H], fmt.Sprint(coeffs))
+	}
+	for h := 2; h <= maxHeight; h++ {
+		got := byHeight[h]
+		sort.Strings(got)
+		want := bruteForce(h)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("height %d: %d polynomials, want %d", h, len(got), len(want))
+		}
+	}
+}
+
+func TestHeightCount(t *testing.T) {
+	total := 0
+	for h := 2; h <= 12; h++ {
+		if got, want := heightCount(h), len(bruteForce(h)); got != want {
+			t.Errorf("heightCount(%d) = %d, want %d", h, got, want)
+		}
+		total += heightCount(h)
+	}
+	if got := len(collect(12, span{})); got != total || totalCount(12) != total {
+		t.Errorf("totalCount(12) = %d, enumeration has %d, want %d", totalCount(12), got, total)
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	var reports []Progress
+	_, enum, err := Run(context.Background(), 10, 1, Options{Progress: func(p Progress) {
+		reports = append(reports, p)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := reports[len(reports)-1]
+	if !last.Finished || last.Done != totalCount(10) || last.Done != last.Total || last.Solved != enum.PolynomialCount() ||
+		last.Roots != enum.Roots || last.Height != 10 || last.HeightDone != last.HeightTotal || last.ETA != 0 {
+		t.Errorf("last report %+v for %+v", last, enum)
+	}
+}
+
+func TestPolynomialsIndexes(t *testing.T) {
+	works := collect(10, span{})
+	for i, w := range works {
+		if w.Index != i || w.Seq != i {
+			t.Fatalf("polynomial %d has index %d, seq %d", i, w.Index, w.Seq)
+		}
+		if i > 0 && w.H < works[i-1].H {
+			t.Fatalf("polynomial %d of height %d follows height %d", i, w.H, works[i-1].H)
+		}
+	}
+}
+
+func TestPolynomialsResume(t *testing.T) {
+	all := collect(10, span{})
+	for _, k := range []int{0, 1, 17, 100, len(all) - 2, len(all) - 1} {
+		pos := all[k].Pos
+		rest := collect(10, span{after: &pos, firstIndex: k + 1})
+		if len(rest) != len(all)-k-1 {
+			t.Fatalf("resuming after %d: %d polynomials, want %d", k, len(rest), len(all)-k-1)
+		}
+		for i, w := range rest {
+			if want := all[k+1+i]; w.Index != want.Index || !reflect.DeepEqual(w.Coeffs, want.Coeffs) {
+				t.Fatalf("resuming after %d: polynomial %d is %v (index %d), want %v (index %d)",
+					k, i, intCoeffs(w), w.Index, intCoeffs(want), want.Index)
+			}
+		}
+	}
+}
+
+func TestPolynomialsShards(t *testing.T) {
+	all := collect(11, span{})
+	for _, n := range []int{2, 3, 5} {
+		seen := make([]bool, len(all))
+		for shard := 1; shard <= n; shard++ {
+			works := collect(11, span{shard: shard, shards: n})
+			if len(works) < len(all)/(2*n) {
+				t.Errorf("shard %d/%d: only %d of %d polynomials", shard, n, len(works), len(all))
+			}
+			for i, w := range works {
+				if w.Seq != i {
+					t.Fatalf("shard %d/%d: polynomial %d has seq %d", shard, n, i, w.Seq)
+				}
+				if seen[w.Index] {
+					t.Fatalf("shards of %d: index %d twice", n, w.Index)
+				}
+				seen[w.Index] = true
+				if !reflect.DeepEqual(w.Coeffs, all[w.Index].Coeffs) {
+					t.Fatalf("shard %d/%d: index %d is %v, want %v", shard, n, w.Index, intCoeffs(w), intCoeffs(all[w.Index]))
+				}
+			}
+		}
+		for i, ok := range seen {
+			if !ok {
+				t.Fatalf("shards of %d: index %d missing", n, i)
+			}
+		}
+	}
+}
+
+func TestRunIsReproducible(t *testing.T) {
+	a, enumA := Generate(context.Background(), 10, 7)
+	b, enumB := Generate(context.Background(), 10, 7)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("two runs with the same seed found different points")
+	}
+	if enumA.Roots != len(a) || enumA.PolynomialCount() != enumB.PolynomialCount() {
+		t.Errorf("enumerations %+v and %+v", enumA, enumB)
+	}
+	for _, p := range a {
+		if len(p.Coeffs) != p.O+1 || p.Coeffs[p.O] != p.LeadingCoeff || p.Mult < 1 {
+			t.Fatalf("bad point %+v", p)
+		}
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	// Batches are solved out of order by any number of workers and put back in order
+	want, _ := Generate(context.Background(), 11, 5)
+	for _, workers := range []int{1, 3, 8} {
+		got, _, err := Run(context.Background(), 11, 5, Options{Workers: workers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: %d points differ from the default run's %d", workers, len(got), len(want))
+		}
+	}
+}
+
+func TestCoordinateMatchesGenerate(t *testing.T) {
+	// One worker takes a batch and goes silent until it is closed mid-run; once its lease
+	// expires the other solves the batch, and the points are those of a local run
+	const maxHeight, seed, lease = 9, 3, 50 * time.Millisecond
+	want, _ := Generate(context.Background(), maxHeight, seed)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	type result struct {
+		points []Point
+		enum   Enumeration
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		points, enum, err := Coordinate(context.Background(), ln, maxHeight, seed, Options{BatchSize: 20, Lease: lease})
+		done <- result{points, enum, err}
+	}()
+
+	newWorker := func(name string) *Worker {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWorker(conn, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	silent := newWorker("silent")
+	var held WorkBatch
+	if err := silent.client.Call("Coordinator.Next", struct{}{}, &held); err != nil || held.Done || len(held.Polys) == 0 {
+		t.Fatalf("silent worker got %+v, %v", held, err)
+	}
+
+	steady := newWorker("steady")
+	solved := make(chan int, 1)
+	go func() {
+		defer steady.Close()
+		_, polys, err := steady.Run(context.Background(), 2)
+		if err != nil {
+			t.Error(err)
+		}
+		solved <- polys
+	}()
+	time.Sleep(2 * lease)
+	silent.Close()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("the coordinator did not finish")
+	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if !reflect.DeepEqual(r.points, want) {
+		t.Errorf("%d coordinated points differ from the %d generated", len(r.points), len(want))
+	}
+	if r.enum.StoppedAt != 0 || r.enum.Roots != len(want) {
+		t.Errorf("enumeration %+v", r.enum)
+	}
+	if polys := <-solved; polys != r.enum.PolynomialCount() {
+		t.Errorf("steady worker solved %d of %d polynomials", polys, r.enum.PolynomialCount())
+	}
+}
+
+func TestCoordinateRejectsBadResults(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := Coordinate(ctx, ln, 6, 1, Options{BatchSize: 5})
+		done <- err
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWorker(conn, "bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch WorkBatch
+	if err := w.client.Call("Coordinator.Next", struct{}{}, &batch); err != nil {
+		t.Fatal(err)
+	}
+	records := func() []Record {
+		rs := make([]Record, len(batch.Polys))
+		for i, p := range batch.Polys {
+			rs[i] = Record{Index: p.Index, Height: p.Height, Coeffs: p.Coeffs}
+		}
+		return rs
+	}
+
+	tooHigh := records()
+	tooHigh[0].Height = 100
+	extraRoot := records()
+	extraRoot[0].Roots, extraRoot[0].Mult = make([]complex128, len(extraRoot[0].Coeffs)), make([]int, len(extraRoot[0].Coeffs))
+	for _, tt := range []struct {
+		name   string
+		result BatchResult
+	}{
+		{"unknown batch", BatchResult{ID: batch.ID + 1000, Records: records()}},
+		{"missing record", BatchResult{ID: batch.ID, Records: records()[1:]}},
+		{"wrong height", BatchResult{ID: batch.ID, Records: tooHigh}},
+		{"too many roots", BatchResult{ID: batch.ID, Records: extraRoot}},
+	} {
+		var finished bool
+		if err := w.client.Call("Coordinator.Submit", tt.result, &finished); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+	var finished bool
+	if err := w.client.Call("Coordinator.Submit", BatchResult{ID: batch.ID, Records: records()}, &finished); err != nil {
+		t.Errorf("matching result: %v", err)
+	}
+	w.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShardsMerge(t *testing.T) {
+	const maxHeight, seed, shards = 10, 3, 3
+	want, wantEnum := Generate(context.Background(), maxHeight, seed)
+
+	dir := t.TempDir()
+	var paths []string
+	for shard := 1; shard <= shards; shard++ {
+		path := filepath.Join(dir, fmt.Sprintf("shard-%d.points", shard))
+		header := PointFileHeader{Seed: seed, MaxHeight: maxHeight, Shard: shard, Shards: shards}
+		pw, err := CreatePointFile(path, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := Run(context.Background(), maxHeight, seed, Options{Shard: shard, Shards: shards, Output: pw}); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	// Out of order on purpose
+	paths[0], paths[2] = paths[2], paths[0]
+	files, err := OpenPointFiles(paths, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	got, enum, err := Merge(files, maxHeight, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged shards: %d points, want the %d of a single run", len(got), len(want))
+	}
+	if !reflect.DeepEqual(enum.Polynomials, wantEnum.Polynomials) || enum.Roots != wantEnum.Roots {
+		t.Errorf("merged enumeration %+v, want %+v", enum, wantEnum)
+	}
+}
+
+func TestMergeRejectsBadHeight(t *testing.T) {
+	// A record above the height its file claims would overrun the enumeration's counts
+	path := filepath.Join(t.TempDir(), "bad.points")
+	header := PointFileHeader{Seed: 1, MaxHeight: 3, Shard: 1, Shards: 1}
+	pw, err := CreatePointFile(path, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.add(Record{Index: 0, Height: 9, Coeffs: []int{-9, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	header.Done = true
+	if err := pw.finish(header); err != nil {
+		t.Fatal(err)
+	}
+	files, err := OpenPointFiles([]string{path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer files[0].Close()
+	if _, _, err := Merge(files, 9, nil); err == nil {
+		t.Error("merged a record of height 9 into an enumeration of heights up to 3")
+	}
+}
+
+// inFamily says whether a polynomial, constant term first, belongs to family
+func inFamily(coeffs []int, family Family) bool {
+	palindromic, anti := true, true
+	for i, c := range coeffs {
+		mirror := coeffs[len(coeffs)-1-i]
+		palindromic = palindromic && c == mirror
+		anti = anti && c == -mirror
+	}
+	switch family {
+	case Palindromic:
+		return palindromic
+	case AntiPalindromic:
+		return anti
+	case Reciprocal:
+		return palindromic || anti
+	}
+	return true
+}
+
+// parseCoeffs reads back a polynomial as bruteForce formats it
+func parseCoeffs(poly string) []int {
+	var coeffs []int
+	for _, f := range strings.Fields(strings.Trim(poly, "[]")) {
+		c, _ := strconv.Atoi(f)
+		coeffs = append(coeffs, c)
+	}
+	return coeffs
+}
+
+// collectFamily runs the family's polynomials over the span and returns the work items it yields
+func collectFamily(maxHeight int, family Family, s span) []Work {
+	var works []Work
+	family.polynomials(maxHeight, s, func(w Work) bool {
+		works = append(works, w)
+		return true
+	})
+	return works
+}
+
+func TestReciprocalPolynomialsMatchBruteForce(t *testing.T) {
+	const maxHeight = 13
+	for _, family := range []Family{Palindromic, AntiPalindromic, Reciprocal} {
+		byHeight := make(map[int][]string)
+		for i, w := range collectFamily(maxHeight, family, span{}) {
+			coeffs := intCoeffs(w)
+			if w.Index != i || len(coeffs) != w.Order+1 || coeffs[w.Order] != w.LeadingCoeff {
+				t.Fatalf("%s: %v has index %d, order %d, leading coefficient %d", family, coeffs, w.Index, w.Order, w.LeadingCoeff)
+			}
+			byHeight[w.H] = append(byHeight[w.H], fmt.Sprint(coeffs))
+		}
+		for h := 2; h <= maxHeight; h++ {
+			got := byHeight[h]
+			sort.Strings(got)
+			var want []string
+			for _, poly := range bruteForce(h) {
+				if inFamily(parseCoeffs(poly), family) {
+					want = append(want, poly)
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s, height %d: %d polynomials, want %d", family, h, len(got), len(want))
+			}
+			if n := family.heightCount(h); n != len(want) {
+				t.Errorf("%s: heightCount(%d) = %d, want %d", family, h, n, len(want))
+			}
+		}
+	}
+}
+
+func TestReciprocalPolynomialsResumeAndShards(t *testing.T) {
+	all := collectFamily(14, Reciprocal, span{})
+	for _, k := range []int{0, 1, 17, len(all) / 2, len(all) - 1} {
+		pos := all[k].Pos
+		rest := collectFamily(14, Reciprocal, span{after: &pos, firstIndex: k + 1})
+		if len(rest) != len(all)-k-1 || (len(rest) > 0 && rest[0].Index != k+1) {
+			t.Fatalf("resuming after %d: %d polynomials, want %d", k, len(rest), len(all)-k-1)
+		}
+		for i, w := range rest {
+			if !reflect.DeepEqual(w.Coeffs, all[k+1+i].Coeffs) {
+				t.Fatalf("resuming after %d: polynomial %d is %v, want %v", k, i, intCoeffs(w), intCoeffs(all[k+1+i]))
+			}
+		}
+	}
+	seen := make([]bool, len(all))
+	for shard := 1; shard <= 3; shard++ {
+		for _, w := range collectFamily(14, Reciprocal, span{shard: shard, shards: 3}) {
+			if seen[w.Index] || !reflect.DeepEqual(w.Coeffs, all[w.Index].Coeffs) {
+				t.Fatalf("shard %d/3: index %d is %v, want %v once", shard, w.Index, intCoeffs(w), intCoeffs(all[w.Index]))
+			}
+			seen[w.Index] = true
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Fatalf("shards of 3: index %d missing", i)
+		}
+	}
+}
+
+func TestFamilyShardsMerge(t *testing.T) {
+	const maxHeight, seed, shards = 12, 4, 2
+	want, _, err := Run(context.Background(), maxHeight, seed, Options{Family: Palindromic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var paths []string
+	for shard := 1; shard <= shards; shard++ {
+		path := filepath.Join(dir, fmt.Sprintf("shard-%d.points", shard))
+		header := PointFileHeader{Seed: seed, MaxHeight: maxHeight, Shard: shard, Shards: shards, Family: Palindromic}
+		pw, err := CreatePointFile(path, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := Options{Shard: shard, Shards: shards, Output: pw, Family: Palindromic}
+		if _, _, err := Run(context.Background(), maxHeight, seed, opts); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	files, err := OpenPointFiles(paths, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	got, enum, err := Merge(files, maxHeight, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enum.Family != Palindromic || !reflect.DeepEqual(got, want) {
+		t.Errorf("merged %s shards: %d points, want the %d of a single run", enum.Family, len(got), len(want))
+	}
+}
+
+func BenchmarkPolynomials(b *testing.B) {
+	// Listing the polynomials alone, without solving them
+	n := 0
+	for i := 0; i < b.N; i++ {
+		Polynomials(15, func(Work) bool {
+			n++
+			return true
+		})
+	}
+	b.ReportMetric(float64(n)/b.Elapsed().Seconds(), "polys/s")
+}
+
+func BenchmarkSolve(b *testing.B) {
+	works := collect(14, span{})
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Solve(works[i%len(works)], 1, rng)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	for _, h := range []int{10, 13} {
+		b.Run(fmt.Sprintf("height-%d", h), func(b *testing.B) {
+			polys := 0
+			for i := 0; i < b.N; i++ {
+				_, enum := Generate(context.Background(), h, 1)
+				polys += enum.PolynomialCount()
+			}
+			b.ReportMetric(float64(polys)/b.Elapsed().Seconds(), "polys/s")
+		})
+	}
+}
+
+func TestClassifyAndRank(t *testing.T) {
+	all := map[roots.Kind]bool{roots.Pisot: true, roots.Salem: true, roots.SmallMeasure: true, roots.Cyclotomic: true}
+
+	// The smallest Pisot number, the plastic number, turns up at height 7
+	points, _ := Generate(context.Background(), 8, 1)
+	pisot := Rank(Classify(points, DefaultSmallMeasure, 0), map[roots.Kind]bool{roots.Pisot: true})
+	if len(pisot) == 0 || math.Abs(pisot[0].Number-1.324717957244746) > 1e-9 || pisot[0].Height != 7 {
+		t.Fatalf("first Pisot entry %+v, want the plastic number at height 7", pisot)
+	}
+
+	// Lehmer's number leads the reciprocal polynomials once its multiple at height 18 is in
+	points, _, err := Run(context.Background(), 18, 1, Options{Family: Reciprocal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	specials := Classify(points, DefaultSmallMeasure, 2)
+	ranked := Rank(specials, all)
+	lehmer := parseCoeffs("[1 1 0 -1 -1 -1 -1 -1 0 1 1]")
+	if len(ranked) == 0 || ranked[0].Kind != roots.Salem || !reflect.DeepEqual(ranked[0].Factor, lehmer) ||
+		math.Abs(ranked[0].Number-1.176280818259918) > 1e-9 {
+		t.Fatalf("first entry %+v, want Lehmer's Salem number", ranked[0])
+	}
+	for i := 1; i < len(ranked); i++ {
+		a, b := ranked[i-1], ranked[i]
+		if a.Kind == roots.Cyclotomic && b.Kind != roots.Cyclotomic {
+			t.Fatalf("%s entry after a cyclotomic one", b.Kind)
+		}
+		if a.Kind != roots.Cyclotomic && b.Kind != roots.Cyclotomic && b.Measure < a.Measure-1e-9 {
+			t.Fatalf("measure %g ranked after %g", b.Measure, a.Measure)
+		}
+	}
+
+	// Its mark comes from the height of the first polynomial with it
+	marks := Marks(specials, map[roots.Kind]bool{roots.Salem: true})
+	found := false
+	for _, m := range marks {
+		if m.Kind != roots.Salem {
+			t.Fatalf("%s mark among Salem ones", m.Kind)
+		}
+		if cmplx.Abs(m.Z-complex(ranked[0].Number, 0)) < 1e-6 {
+			found = true
+			if m.H != ranked[0].Height {
+				t.Errorf("Lehmer's number marked from height %d, want %d", m.H, ranked[0].Height)
+			}
+		}
+	}
+	if !found {
+		t.Error("Lehmer's number is not marked")
+	}
+	if len(MarksUpTo(marks, ranked[0].Height-1)) >= len(marks) {
+		t.Error("MarksUpTo kept marks from higher heights")
+	}
+}
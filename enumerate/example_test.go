@@ -0,0 +1,70 @@
+package enumerate_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+func ExampleGenerate() {
+	// A fixed seed finds the same roots every time
+	points, enum := enumerate.Generate(context.Background(), 10, 42)
+	fmt.Printf("%d polynomials, %d roots\n", enum.PolynomialCount(), len(points))
+}
+
+func ExamplePolynomials() {
+	enumerate.Polynomials(4, func(w enumerate.Work) bool {
+		coeffs := make([]int, len(w.Coeffs))
+		for i, c := range w.Coeffs {
+			coeffs[i] = int(real(c))
+		}
+		fmt.Printf("%d  height %d  %s\n", w.Index, w.H, roots.FormatPolynomial(coeffs))
+		return true
+	})
+	// Output:
+	// 0  height 3  x
+	// 1  height 4  x + 1
+	// 2  height 4  x - 1
+	// 3  height 4  2x
+	// 4  height 4  x^2
+}
+
+func ExampleSolve() {
+	// x^2 - x - 1, constant term first
+	work := enumerate.Work{Coeffs: []complex128{-1, -1, 1}, H: 5, Order: 2, LeadingCoeff: 1}
+	// The roots depend only on the seed and the coefficients, not on the state of rng
+	r := enumerate.Solve(work, 42, rand.New(rand.NewSource(1)))
+	sort.Slice(r.Roots, func(i, j int) bool { return real(r.Roots[i]) < real(r.Roots[j]) })
+	for _, z := range r.Roots {
+		fmt.Printf("%.6f\n", real(z))
+	}
+	fmt.Printf("Mahler measure %.6f\n", r.Mahler)
+	// Output:
+	// -0.618034
+	// 1.618034
+	// Mahler measure 1.618034
+}
+
+func ExampleNewWorker() {
+	// Solve for a coordinator started with: algebraic coordinator --listen render-host:7070
+	conn, err := net.Dial("tcp", "render-host:7070")
+	if err != nil {
+		log.Fatal(err)
+	}
+	worker, err := enumerate.NewWorker(conn, "example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer worker.Close()
+	batches, polys, err := worker.Run(context.Background(), 4)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Solved %d batches (%d polynomials)\n", batches, polys)
+}
@@ -0,0 +1,133 @@
+package enumerate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/jayalane/algebraic_vis/internal/logging"
+)
+
+// PointFile is one open point file of a sharded enumeration
+type PointFile struct {
+	path   string
+	file   *os.File
+	br     *bufio.Reader
+	Header PointFileHeader
+	next   *Record // nil once the file is exhausted
+}
+
+// advance reads the source's next record
+func (s *PointFile) advance() error {
+	r, err := readRecord(s.br)
+	if err == io.EOF {
+		s.next = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	s.next = &r
+	return nil
+}
+
+// Close closes the file
+func (s *PointFile) Close() error {
+	return s.file.Close()
+}
+
+// OpenPointFiles opens the point files of a sharded enumeration and checks that they
+// are its shards, each exactly once, logging each to logger if it is not nil
+func OpenPointFiles(paths []string, logger *slog.Logger) ([]*PointFile, error) {
+	var sources []*PointFile
+	closeAll := func() {
+		for _, s := range sources {
+			s.Close()
+		}
+	}
+	seen := make(map[int]string)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		s := &PointFile{path: path, file: file, br: bufio.NewReader(file)}
+		sources = append(sources, s)
+		if s.Header, err = readPointFileHeader(s.br); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		logging.Or(logger).Info("Opened point file", "path", path, "contents", s.Header.describe())
+
+		first := sources[0].Header
+		switch {
+		case !s.Header.Done:
+			err = fmt.Errorf("%s is unfinished; let its run end, or resume it from its checkpoint", path)
+		case s.Header.Seed != first.Seed || s.Header.MaxHeight != first.MaxHeight || s.Header.Shards != first.Shards ||
+			s.Header.Family != first.Family:
+			err = fmt.Errorf("%s and %s are not shards of the same enumeration", sources[0].path, path)
+		case seen[s.Header.Shard] != "":
+			err = fmt.Errorf("%s and %s are both shard %d", seen[s.Header.Shard], path, s.Header.Shard)
+		}
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		seen[s.Header.Shard] = path
+	}
+	if shards := sources[0].Header.Shards; len(sources) != shards {
+		closeAll()
+		return nil, fmt.Errorf("got %d of %d shards", len(sources), shards)
+	}
+	return sources, nil
+}
+
+// Merge reads the shards back into one enumeration, ordered as a single run
+// would have found the points, keeping heights up to maxHeight. The outcome is logged to
+// logger if it is not nil.
+func Merge(sources []*PointFile, maxHeight int, logger *slog.Logger) ([]Point, Enumeration, error) {
+	first := sources[0].Header
+	enum := Enumeration{MaxHeight: first.MaxHeight, Seed: first.Seed, Family: first.Family, Polynomials: make([]int, first.MaxHeight+1)}
+	for _, s := range sources {
+		if err := s.advance(); err != nil {
+			return nil, enum, err
+		}
+		// The shards ran side by side, so the enumeration took as long as the slowest
+		if s.Header.Elapsed > enum.Duration {
+			enum.Duration = s.Header.Elapsed
+		}
+		if s.Header.StoppedAt > 0 && (enum.StoppedAt == 0 || s.Header.StoppedAt < enum.StoppedAt) {
+			enum.StoppedAt = s.Header.StoppedAt
+		}
+	}
+
+	var points []Point
+	for {
+		// Every shard is in enumeration order; take the lowest index among them
+		var s *PointFile
+		for _, c := range sources {
+			if c.next != nil && (s == nil || c.next.Index < s.next.Index) {
+				s = c
+			}
+		}
+		if s == nil {
+			break
+		}
+		// Heights past the enumeration's would overrun its counts; the shard is corrupt
+		if r := s.next; r.Height < 1 || r.Height > enum.MaxHeight {
+			return nil, enum, fmt.Errorf("%s: polynomial %d has height %d, outside 1-%d", s.path, r.Index, r.Height, enum.MaxHeight)
+		} else if r.Height <= maxHeight {
+			points = append(points, r.points()...)
+			enum.Polynomials[r.Height]++
+			enum.Roots += len(r.Roots)
+		}
+		if err := s.advance(); err != nil {
+			return nil, enum, err
+		}
+	}
+	logDone(logging.Or(logger), "Merged", enum)
+	return points, enum, nil
+}
@@ -0,0 +1,294 @@
+package enumerate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// A point file holds solved polynomials in enumeration order, one record each:
+//
+//	uvarint index        position in the enumeration
+//	uvarint height
+//	uvarint degree
+//	varint  coefficients  degree+1 of them, constant term first
+//	float64 |discriminant|
+//	float64 Mahler measure
+//	uvarint root count
+//	per root: float64 real, float64 imaginary, uvarint multiplicity
+//
+// Floats are little-endian IEEE 754. The file starts with pointFileMagic and a fixed-size
+// header, which is rewritten when the enumeration that writes the file ends:
+//
+//	int64  seed
+//	int64  enumeration time in nanoseconds
+//	uint32 max height, shard, shard count, height an interrupt stopped at, done (0 or 1),
+//	       polynomial family
+//
+// Files from before families, with magic pointFileMagicV1, lack the last field and hold
+// all polynomials.
+
+const (
+	pointFileMagic   = "ALGPTS2\n"
+	pointFileMagicV1 = "ALGPTS1\n"
+)
+
+// pointHeaderSize is the length of the header after the magic; a version 1 header is one
+// field shorter
+const pointHeaderSize = 8 + 8 + 6*4
+
+// PointFileHeader describes the enumeration that wrote a point file
+type PointFileHeader struct {
+	Seed      int64
+	Elapsed   time.Duration
+	MaxHeight int
+	Shard     int // 1-based; 1 of 1 for an unsharded run
+	Shards    int
+	StoppedAt int  // As in Enumeration
+	Done      bool // The enumeration ended, interrupted or not
+	Family    Family
+	v1        bool // Read from a version 1 file, whose header has no room for Family
+}
+
+// encode returns the header as stored after the magic
+func (h PointFileHeader) encode() []byte {
+	buf := make([]byte, 0, pointHeaderSize)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.Seed))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.Elapsed))
+	done := 0
+	if h.Done {
+		done = 1
+	}
+	for _, v := range []int{h.MaxHeight, h.Shard, h.Shards, h.StoppedAt, done, int(h.Family)} {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+	}
+	return buf
+}
+
+// describe names the shard and says whether the file is complete
+func (h PointFileHeader) describe() string {
+	status := "complete"
+	switch {
+	case !h.Done:
+		status = "unfinished"
+	case h.StoppedAt > 0:
+		status = DescribeStop(h.StoppedAt)
+	}
+	family := ""
+	if h.Family != AllPolynomials {
+		family = fmt.Sprintf(", %s polynomials", h.Family)
+	}
+	return fmt.Sprintf("seed %d, max-height %d, shard %d/%d%s, %s", h.Seed, h.MaxHeight, h.Shard, h.Shards, family, status)
+}
+
+// Record is one solved polynomial
+type Record struct {
+	Index        int
+	Height       int
+	Coeffs       []int // Constant term first; the last is the leading coefficient
+	Disc, Mahler float64
+	Roots        []complex128
+	Mult         []int // Multiplicity of each root
+}
+
+// points returns the roots as Points sharing the record's coefficients
+func (r Record) points() []Point {
+	order := len(r.Coeffs) - 1
+	points := make([]Point, len(r.Roots))
+	for i, z := range r.Roots {
+		points[i] = Point{
+			Z:            z,
+			H:            r.Height,
+			O:            order,
+			LeadingCoeff: r.Coeffs[order],
+			Disc:         r.Disc,
+			Mahler:       r.Mahler,
+			Mult:         r.Mult[i],
+			Coeffs:       r.Coeffs,
+		}
+	}
+	return points
+}
+
+// appendRecord encodes r onto buf
+func appendRecord(buf []byte, r Record) []byte {
+	buf = binary.AppendUvarint(buf, uint64(r.Index))
+	buf = binary.AppendUvarint(buf, uint64(r.Height))
+	buf = binary.AppendUvarint(buf, uint64(len(r.Coeffs)-1))
+	for _, c := range r.Coeffs {
+		buf = binary.AppendVarint(buf, int64(c))
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(r.Disc))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(r.Mahler))
+	buf = binary.AppendUvarint(buf, uint64(len(r.Roots)))
+	for i, z := range r.Roots {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(real(z)))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(imag(z)))
+		buf = binary.AppendUvarint(buf, uint64(r.Mult[i]))
+	}
+	return buf
+}
+
+// readRecord decodes the next record; it returns io.EOF at a clean end of file
+func readRecord(br *bufio.Reader) (Record, error) {
+	var r Record
+	index, err := binary.ReadUvarint(br)
+	if err != nil {
+		return r, err // io.EOF between records
+	}
+	fail := func(err error) (Record, error) {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, fmt.Errorf("corrupt point record %d: %v", index, err)
+	}
+	uvarint := func() int {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = binary.ReadUvarint(br)
+		return int(v)
+	}
+	float := func() float64 {
+		if err != nil {
+			return 0
+		}
+		var b [8]byte
+		_, err = io.ReadFull(br, b[:])
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+	}
+
+	r.Index = int(index)
+	r.Height = uvarint()
+	degree := uvarint()
+	if err == nil && degree > r.Height {
+		return fail(fmt.Errorf("degree %d above height %d", degree, r.Height))
+	}
+	r.Coeffs = make([]int, degree+1)
+	for i := range r.Coeffs {
+		if err != nil {
+			break
+		}
+		var c int64
+		c, err = binary.ReadVarint(br)
+		r.Coeffs[i] = int(c)
+	}
+	r.Disc = float()
+	r.Mahler = float()
+	n := uvarint()
+	if err == nil && n > degree {
+		return fail(fmt.Errorf("%d roots for degree %d", n, degree))
+	}
+	r.Roots = make([]complex128, n)
+	r.Mult = make([]int, n)
+	for i := 0; i < n && err == nil; i++ {
+		re, im := float(), float()
+		r.Roots[i] = complex(re, im)
+		r.Mult[i] = uvarint()
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return r, nil
+}
+
+// readPointFileHeader checks the magic at the start of a point file and reads the header
+func readPointFileHeader(br *bufio.Reader) (PointFileHeader, error) {
+	var h PointFileHeader
+	magic := make([]byte, len(pointFileMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return h, fmt.Errorf("not a point file")
+	}
+	size := pointHeaderSize
+	switch string(magic) {
+	case pointFileMagic:
+	case pointFileMagicV1:
+		size -= 4
+		h.v1 = true
+	default:
+		return h, fmt.Errorf("not a point file")
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return h, fmt.Errorf("truncated point file header")
+	}
+	h.Seed = int64(binary.LittleEndian.Uint64(buf))
+	h.Elapsed = time.Duration(binary.LittleEndian.Uint64(buf[8:]))
+	field := func(i int) int {
+		return int(binary.LittleEndian.Uint32(buf[16+4*i:]))
+	}
+	h.MaxHeight, h.Shard, h.Shards, h.StoppedAt = field(0), field(1), field(2), field(3)
+	h.Done = field(4) != 0
+	if size == pointHeaderSize {
+		h.Family = Family(field(5))
+	}
+	if h.Family < AllPolynomials || h.Family > Reciprocal {
+		return h, fmt.Errorf("unknown polynomial family %d in point file header", h.Family)
+	}
+	if h.Shards < 1 || h.Shard < 1 || h.Shard > h.Shards {
+		return h, fmt.Errorf("bad shard %d/%d in point file header", h.Shard, h.Shards)
+	}
+	return h, nil
+}
+
+// PointWriter appends records to a point file
+type PointWriter struct {
+	file *os.File
+	w    *bufio.Writer
+	buf  []byte
+	size int64 // Bytes written so far, flushed or not
+}
+
+// CreatePointFile starts a point file with header h
+func CreatePointFile(path string, h PointFileHeader) (*PointWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create point file: %v", err)
+	}
+	pw := &PointWriter{file: file, w: bufio.NewWriter(file)}
+	pw.w.WriteString(pointFileMagic)
+	pw.w.Write(h.encode())
+	pw.size = int64(len(pointFileMagic) + pointHeaderSize)
+	return pw, nil
+}
+
+// add appends a record
+func (pw *PointWriter) add(r Record) error {
+	pw.buf = appendRecord(pw.buf[:0], r)
+	if _, err := pw.w.Write(pw.buf); err != nil {
+		return fmt.Errorf("failed to write point file: %v", err)
+	}
+	pw.size += int64(len(pw.buf))
+	return nil
+}
+
+// sync makes the records added so far durable
+func (pw *PointWriter) sync() error {
+	if err := pw.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write point file: %v", err)
+	}
+	if err := pw.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync point file: %v", err)
+	}
+	return nil
+}
+
+// finish rewrites the header as h and closes the file
+func (pw *PointWriter) finish(h PointFileHeader) error {
+	err := pw.sync()
+	if err == nil {
+		if _, werr := pw.file.WriteAt(h.encode(), int64(len(pointFileMagic))); werr != nil {
+			err = fmt.Errorf("failed to write point file header: %v", werr)
+		}
+	}
+	if cerr := pw.file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to close point file: %v", cerr)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package enumerate
+
+import (
+	"sync"
+	"time"
+)
+
+// heightCount returns the number of polynomials of height h: those of degree n >= 1 with a
+// positive leading coefficient a and n + 1 + a + sum |c_i| = h over the other coefficients
+func heightCount(h int) int {
+	count := 0
+	for n := 1; n+2 <= h; n++ {
+		// vectors[k] counts the n lower coefficients with sum |c_i| = k
+		left := h - 2 - n
+		vectors := make([]int, left+1)
+		vectors[0] = 1
+		for i := 0; i < n; i++ {
+			next := make([]int, left+1)
+			for k := range next {
+				next[k] = vectors[k]
+				for c := 1; c <= k; c++ {
+					next[k] += 2 * vectors[k-c] // c_i = c or -c
+				}
+			}
+			vectors = next
+		}
+		for a := 1; a <= left+1; a++ {
+			count += vectors[left+1-a]
+		}
+	}
+	return count
+}
+
+// totalCount returns the number of polynomials of heights up to maxHeight
+func totalCount(maxHeight int) int {
+	total := 0
+	for h := 2; h <= maxHeight; h++ {
+		total += heightCount(h)
+	}
+	return total
+}
+
+// Progress is a report on a running enumeration. Done counts from the start of the
+// enumeration order, so a shard counts the other shards' polynomials it has passed and a
+// resumed run those of earlier sessions.
+type Progress struct {
+	Height      int           // Height being solved
+	MaxHeight   int           // Last height of the run
+	HeightDone  int           // Polynomials of Height done
+	HeightTotal int           // Polynomials of Height
+	Done        int           // Polynomials of the enumeration done
+	Total       int           // Polynomials up to MaxHeight
+	Solved      int           // Polynomials this run has solved
+	Roots       int           // Roots found, by earlier sessions too
+	Rate        float64       // Polynomials solved per second
+	Elapsed     time.Duration // Time this run has taken
+	ETA         time.Duration // Estimated time left; -1 until there is a rate to go on
+	Finished    bool          // The run is over, finished or stopped
+}
+
+// progressEvery is the interval between progress reports
+const progressEvery = time.Second
+
+// progressTracker turns the polynomials a run has done into Progress reports
+type progressTracker struct {
+	report func(Progress)
+
+	mu        sync.Mutex
+	start     time.Time
+	last      time.Time // Last report
+	startDone int       // Done when this run started
+	heightEnd []int     // Done at the end of each height, indexed by height
+	p         Progress
+}
+
+// newProgressTracker starts tracking a run that has done polynomials and found roots
+// before it, where count gives the polynomials of each height; report may be nil
+func newProgressTracker(report func(Progress), count func(h int) int, maxHeight, done, roots int) *progressTracker {
+	t := &progressTracker{report: report, start: time.Now(), startDone: done,
+		heightEnd: make([]int, maxHeight+1)}
+	end := 0
+	for h := 2; h <= maxHeight; h++ {
+		end += count(h)
+		t.heightEnd[h] = end
+	}
+	t.last = t.start
+	t.p = Progress{MaxHeight: maxHeight, Total: end, Roots: roots, ETA: -1}
+	t.setDone(done)
+	return t
+}
+
+// setDone moves the position to done polynomials in enumeration order; t.mu must be held
+// or t not shared yet
+func (t *progressTracker) setDone(done int) {
+	t.p.Done = done
+	if t.p.MaxHeight < 2 {
+		return
+	}
+	h := 2
+	for h < t.p.MaxHeight && t.heightEnd[h] <= done {
+		h++
+	}
+	t.p.Height = h
+	t.p.HeightTotal = t.heightEnd[h] - t.heightEnd[h-1]
+	t.p.HeightDone = done - t.heightEnd[h-1]
+}
+
+// add counts solved polynomials with their roots; index is that of the last in enumeration
+// order, or -1 to leave the position to solved
+func (t *progressTracker) add(index, solved, roots int) {
+	if t.report == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.Solved += solved
+	t.p.Roots += roots
+	if index >= 0 {
+		t.setDone(index + 1)
+	} else {
+		t.setDone(t.startDone + t.p.Solved)
+	}
+	if now := time.Now(); now.Sub(t.last) >= progressEvery {
+		t.last = now
+		t.send(now)
+	}
+}
+
+// finish sends the last report
+func (t *progressTracker) finish() {
+	if t.report == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.Finished = true
+	t.send(time.Now())
+}
+
+// send fills in the rates and reports; t.mu must be held
+func (t *progressTracker) send(now time.Time) {
+	p := t.p
+	p.Elapsed = now.Sub(t.start)
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.Rate = float64(p.Solved) / secs
+		// Estimated by how fast the position moves, which covers a shard's skipping too
+		if moved := p.Done - t.startDone; moved > 0 && !p.Finished {
+			p.ETA = time.Duration(float64(p.Total-p.Done) / float64(moved) * float64(p.Elapsed))
+		}
+	}
+	if p.Finished {
+		p.ETA = 0
+	}
+	t.report(p)
+}
@@ -0,0 +1,240 @@
+package enumerate
+
+import "fmt"
+
+// Family restricts an enumeration to polynomials whose coefficients read the same forwards
+// and backwards, up to sign. Their roots come in pairs z, 1/z, and they are where Salem and
+// Pisot numbers and Lehmer's problem live.
+type Family int
+
+const (
+	AllPolynomials  Family = iota
+	Palindromic            // c_i = c_{n-i}, also called self-reciprocal
+	AntiPalindromic        // c_i = -c_{n-i}, also called anti-reciprocal; always divisible by x - 1
+	Reciprocal             // Palindromic or anti-palindromic
+)
+
+// familyNames are the names of the families, indexed by Family
+var familyNames = []string{"all", "palindromic", "anti-palindromic", "reciprocal"}
+
+// FamilyList names the families for help text
+const FamilyList = "all, palindromic, anti-palindromic or reciprocal (both)"
+
+func (f Family) String() string {
+	if f < 0 || int(f) >= len(familyNames) {
+		return fmt.Sprintf("Family(%d)", int(f))
+	}
+	return familyNames[f]
+}
+
+// ParseFamily reads a family name; self-reciprocal and anti-reciprocal are accepted too
+func ParseFamily(name string) (Family, error) {
+	switch name {
+	case "self-reciprocal":
+		return Palindromic, nil
+	case "anti-reciprocal":
+		return AntiPalindromic, nil
+	}
+	for f, n := range familyNames {
+		if n == name {
+			return Family(f), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown polynomial family %q (choose from %s)", name, FamilyList)
+}
+
+// MarshalText writes the family's name, e.g. in a checkpoint
+func (f Family) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText reads a family's name
+func (f *Family) UnmarshalText(text []byte) error {
+	family, err := ParseFamily(string(text))
+	*f = family
+	return err
+}
+
+// heightCount returns the number of polynomials of the family of height h
+func (f Family) heightCount(h int) int {
+	if f == AllPolynomials {
+		return heightCount(h)
+	}
+	count := 0
+	halfPatterns(h, f, func(p halfPattern) bool {
+		count += 1 << p.signBits()
+		return true
+	})
+	return count
+}
+
+// Polynomials is Polynomials for the polynomials of the family
+func (f Family) Polynomials(maxHeight int, yield func(Work) bool) {
+	f.polynomials(maxHeight, span{}, yield)
+}
+
+// polynomials is polynomials or reciprocalPolynomials for the family
+func (f Family) polynomials(maxHeight int, s span, yield func(Work) bool) {
+	if f == AllPolynomials {
+		polynomials(maxHeight, s, yield)
+		return
+	}
+	reciprocalPolynomials(maxHeight, f, s, yield)
+}
+
+// halfPattern fixes the coefficient magnitudes of a palindromic or anti-palindromic
+// polynomial by its upper half: the leading coefficient, the magnitudes of the coefficients
+// c_{n-1} down to the middle, and for even degree the middle one
+type halfPattern struct {
+	order   int
+	anti    bool
+	leading int
+	pairs   []int // |c_{n-j}| for j = 1 .. (n+1)/2 - 1, each shared with c_j
+	middle  int   // |c_{n/2}| for even n; always 0 when anti
+}
+
+// signBits counts the coefficients whose sign the pattern leaves open
+func (p halfPattern) signBits() int {
+	bits := 0
+	for _, m := range p.pairs {
+		if m != 0 {
+			bits++
+		}
+	}
+	if p.middle != 0 {
+		bits++
+	}
+	return bits
+}
+
+// coeffs builds the polynomial with the signs of signs' bits, constant term first
+func (p halfPattern) coeffs(signs int) []complex128 {
+	n := p.order
+	mirror := 1.0
+	if p.anti {
+		mirror = -1
+	}
+	coeffs := make([]complex128, n+1)
+	coeffs[n] = complex(float64(p.leading), 0)
+	coeffs[0] = complex(mirror*float64(p.leading), 0)
+	bit := 0
+	sign := func(m int) float64 {
+		if m == 0 {
+			return 0
+		}
+		s := float64(m)
+		if (signs>>bit)&1 == 1 {
+			s = -s
+		}
+		bit++
+		return s
+	}
+	for j, m := range p.pairs {
+		c := sign(m)
+		coeffs[n-1-j] = complex(c, 0)
+		coeffs[1+j] = complex(mirror*c, 0)
+	}
+	if n%2 == 0 {
+		coeffs[n/2] = complex(sign(p.middle), 0)
+	}
+	return coeffs
+}
+
+// halfPatterns passes the patterns of height h in the family to yield, in a fixed order:
+// by degree, palindromic before anti-palindromic, then by leading coefficient, middle and
+// pair magnitudes. It stops when yield returns false and says whether it got to the end.
+func halfPatterns(h int, family Family, yield func(halfPattern) bool) bool {
+	for n := 1; n+2 <= h; n++ {
+		for _, anti := range []bool{false, true} {
+			if (anti && family == Palindromic) || (!anti && family == AntiPalindromic) {
+				continue
+			}
+			// 2*(leading + sum of pairs) + middle = h - 1 - n
+			budget := h - 1 - n
+			maxMiddle := 0
+			if n%2 == 0 && !anti {
+				maxMiddle = budget
+			}
+			pairs := make([]int, (n+1)/2-1)
+			for middle := 0; middle <= maxMiddle; middle++ {
+				if (budget-middle)%2 != 0 {
+					continue
+				}
+				half := (budget - middle) / 2
+				for leading := 1; leading <= half; leading++ {
+					p := halfPattern{order: n, anti: anti, leading: leading, pairs: pairs, middle: middle}
+					if !fillPairs(pairs, 0, half-leading, func() bool { return yield(p) }) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// fillPairs sets pairs[i:] to every split of left, the first taking the most first, and
+// calls yield after each; it stops when yield returns false
+func fillPairs(pairs []int, i, left int, yield func() bool) bool {
+	if i == len(pairs) {
+		return left != 0 || yield()
+	}
+	for m := left; m >= 0; m-- {
+		pairs[i] = m
+		if !fillPairs(pairs, i+1, left-m, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// reciprocalPolynomials is polynomials for a family other than AllPolynomials: it passes the
+// family's polynomials of heights up to maxHeight to yield in enumeration order, building each
+// from half its coefficients. Position.Pattern counts the halfPatterns of a height.
+func reciprocalPolynomials(maxHeight int, family Family, s span, yield func(Work) bool) {
+	after, shard, shards := s.after, s.shard, s.shards
+	index := s.firstIndex
+	seq := 0
+	firstHeight := 2
+	if after != nil {
+		firstHeight = after.Height
+	}
+	for h := firstHeight; h <= maxHeight; h++ {
+		pattern := -1
+		ok := halfPatterns(h, family, func(p halfPattern) bool {
+			pattern++
+			if after != nil && h == after.Height && pattern < after.Pattern {
+				return true
+			}
+			// Neighbouring patterns go to different shards, as with shardOf
+			if shards > 1 && (h+pattern)%shards != shard-1 {
+				index += 1 << p.signBits()
+				return true
+			}
+			firstSigns := 0
+			if after != nil && h == after.Height && pattern == after.Pattern {
+				firstSigns = after.Signs + 1
+			}
+			for signs := firstSigns; signs < 1<<p.signBits(); signs++ {
+				work := Work{
+					Index:        index,
+					Seq:          seq,
+					Pos:          Position{Height: h, Pattern: pattern, Signs: signs},
+					Coeffs:       p.coeffs(signs),
+					H:            h,
+					Order:        p.order,
+					LeadingCoeff: p.leading,
+				}
+				if !yield(work) {
+					return false
+				}
+				index++
+				seq++
+			}
+			return true
+		})
+		if !ok {
+			return
+		}
+	}
+}
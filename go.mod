@@ -1,3 +1,3 @@
-module algebraic
+module github.com/jayalane/algebraic_vis
 
 go 1.21
\ No newline at end of file
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+// highlightNames are the --highlight values and the kinds they mark
+var highlightNames = map[string][]roots.Kind{
+	"pisot":         {roots.Pisot},
+	"salem":         {roots.Salem},
+	"small-measure": {roots.SmallMeasure},
+	"cyclotomic":    {roots.Cyclotomic},
+	"all":           {roots.Pisot, roots.Salem, roots.SmallMeasure, roots.Cyclotomic},
+}
+
+// highlightList names the --highlight values for help text
+const highlightList = "pisot, salem, small-measure, cyclotomic or all"
+
+// parseHighlight reads a comma-separated --highlight value
+func parseHighlight(list string) (map[roots.Kind]bool, error) {
+	kinds := make(map[roots.Kind]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ks, ok := highlightNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown highlight %q (choose from %s)", name, highlightList)
+		}
+		for _, k := range ks {
+			kinds[k] = true
+		}
+	}
+	return kinds, nil
+}
+
+// highlight classifies the job's points up to its height, sets the marks to draw and
+// writes the ranked list if the job asks for either
+func (j *renderJob) highlight(points []enumerate.Point) error {
+	if len(j.Highlight) == 0 && j.HighlightList == "" {
+		return nil
+	}
+	var upTo []enumerate.Point
+	for _, p := range points {
+		if p.H <= j.Config.MaxHeight {
+			upTo = append(upTo, p)
+		}
+	}
+	start := time.Now()
+	specials := enumerate.Classify(upTo, j.SmallMeasure, *j.flags.resources.workers)
+	slog.Info("Classified polynomials", "points", len(upTo), "special", len(specials),
+		"duration", time.Since(start).Round(time.Millisecond))
+	j.Config.Marks = enumerate.Marks(specials, j.Highlight)
+
+	if j.HighlightList == "" {
+		return nil
+	}
+	// The list covers what is highlighted, or everything if nothing is
+	kinds := j.Highlight
+	if len(kinds) == 0 {
+		kinds, _ = parseHighlight("all")
+	}
+	ranked := enumerate.Rank(specials, kinds)
+	if err := writeHighlightList(j.HighlightList, ranked); err != nil {
+		return fmt.Errorf("highlight-list: %v", err)
+	}
+	slog.Info("Saved ranked list", "path", j.HighlightList, "entries", len(ranked))
+	return nil
+}
+
+// rankedEntry is an entry of a JSON ranked list
+type rankedEntry struct {
+	Rank       int     `json:"rank"`
+	Kind       string  `json:"kind"`
+	Measure    float64 `json:"measure"`
+	Number     float64 `json:"number,omitempty"`
+	Degree     int     `json:"degree"`
+	Factor     string  `json:"factor"`
+	Polynomial string  `json:"polynomial"`
+	Height     int     `json:"height"`
+	Count      int     `json:"count"`
+}
+
+// writeHighlightList writes the ranked list as CSV or JSON, by the file's extension
+func writeHighlightList(path string, ranked []enumerate.Ranked) error {
+	entries := make([]rankedEntry, len(ranked))
+	for i, r := range ranked {
+		entries[i] = rankedEntry{
+			Rank:       i + 1,
+			Kind:       r.Kind.String(),
+			Measure:    r.Measure,
+			Number:     r.Number,
+			Degree:     len(r.Factor) - 1,
+			Factor:     roots.FormatPolynomial(r.Factor),
+			Polynomial: roots.FormatPolynomial(r.Polynomial),
+			Height:     r.Height,
+			Count:      r.Count,
+		}
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(data, '\n'), 0644)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"rank", "kind", "measure", "number", "degree", "factor", "polynomial", "height", "count"})
+	for _, e := range entries {
+		number := ""
+		if e.Number != 0 {
+			number = strconv.FormatFloat(e.Number, 'f', 12, 64)
+		}
+		w.Write([]string{strconv.Itoa(e.Rank), e.Kind, strconv.FormatFloat(e.Measure, 'f', 12, 64), number,
+			strconv.Itoa(e.Degree), e.Factor, e.Polynomial, strconv.Itoa(e.Height), strconv.Itoa(e.Count)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jayalane/algebraic_vis/render"
+)
+
+// imageJob returns the job file embedded in a PNG written by this program, warning if the
+// job will not reproduce its points
+func imageJob(path string) (string, error) {
+	chunks, err := render.ReadPNGText(path)
+	if err != nil {
+		return "", err
+	}
+	warnSolver(path, chunks)
+	for _, c := range chunks {
+		if c.Key == render.JobChunkKey {
+			return c.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no embedded job; it was not rendered by this version of %s", path, filepath.Base(os.Args[0]))
+}
+
+// warnSolver warns if an image's points were found by another version of the root finder,
+// so that rendering its job now gives other points
+func warnSolver(path string, chunks []render.TextChunk) {
+	for _, c := range chunks {
+		if c.Key == render.SolverChunkKey && c.Value != render.Solver() {
+			slog.Warn(path+" was solved by another version of the root finder; rendering its job now finds other points",
+				"recorded", c.Value, "current", render.Solver())
+		}
+	}
+}
+
+// printInfoUsage prints the info subcommand help
+func printInfoUsage(progName string) {
+	fmt.Printf("Usage: %s info [flags] IMAGE.png...\n", progName)
+	fmt.Printf("  Prints the metadata recorded in rendered PNGs: software version, seed, enumeration and\n")
+	fmt.Printf("  solver parameters, point counts, timing and the full job that reproduces the image.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --job             Print only the embedded job file (TOML), e.g. to edit and render with --config\n")
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s info algebraic_numbers.png\n", progName)
+	fmt.Printf("  %s info --job poster.png > poster.toml\n", progName)
+}
+
+// runInfo implements the info subcommand
+func runInfo(progName string, args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	jobOnly := fs.Bool("job", false, "Print only the embedded job file")
+	fs.Usage = func() {
+		printInfoUsage(progName)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	for i, path := range fs.Args() {
+		if *jobOnly {
+			job, err := imageJob(path)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			fmt.Print(job)
+			continue
+		}
+
+		chunks, err := render.ReadPNGText(path)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		warnSolver(path, chunks)
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s:\n", path)
+		if len(chunks) == 0 {
+			fmt.Printf("  (no metadata)\n")
+		}
+		for _, c := range chunks {
+			if !strings.Contains(c.Value, "\n") {
+				fmt.Printf("  %-14s %s\n", c.Key+":", c.Value)
+				continue
+			}
+			fmt.Printf("  %s:\n", c.Key)
+			for _, line := range strings.Split(strings.TrimRight(c.Value, "\n"), "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+}
+
+// printRerenderUsage prints the rerender subcommand help
+func printRerenderUsage(progName string) {
+	fmt.Printf("Usage: %s rerender [flags] IMAGE.png\n", progName)
+	fmt.Printf("  Renders an image again from the job and seed embedded in it. With the same version of\n")
+	fmt.Printf("  the program the result is identical, and a warning says when the image's points were found\n")
+	fmt.Printf("  by another version of the root finder. Any render flag overrides the embedded job, so an\n")
+	fmt.Printf("  image can be remade larger, deeper or in other colors.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --output FILE     Output filename (default: IMAGE_rerender.png)\n")
+	fmt.Printf("  Any render, logging or profiling flag of %s itself, e.g. --max-height, --width, --palette or --center\n", progName)
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s rerender poster.png\n", progName)
+	fmt.Printf("  %s rerender --width 3600 --height 2400 --output poster_large.png poster.png\n", progName)
+}
+
+// runRerender implements the rerender subcommand
+func runRerender(progName string, args []string) {
+	rf := newRenderFlags("rerender", flag.ExitOnError)
+	lf := addLogFlags(rf.fs)
+	pf := addProfileFlags(rf.fs)
+	rf.fs.Usage = func() {
+		printRerenderUsage(progName)
+	}
+	rf.fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *rf.help || *rf.helpLong {
+		printRerenderUsage(progName)
+		return
+	}
+	if rf.fs.NArg() != 1 {
+		rf.fs.Usage()
+		os.Exit(1)
+	}
+	image := rf.fs.Arg(0)
+	job, err := imageJob(image)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	settings, err := parseTOML(job)
+	if err != nil {
+		fatalf("%s: embedded job: %v", image, err)
+	}
+
+	// Never overwrite the original by default
+	if *rf.outputFile == "" {
+		ext := filepath.Ext(image)
+		rf.fs.Set("output", strings.TrimSuffix(image, ext)+"_rerender"+ext)
+	}
+
+	// The job supplies the view unless a flag replaces it
+	rf.baseSource = image
+	rf.baseSettings = settings
+	resolved, err := rf.resolve(nil)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+	slog.Info("Rerendering", "image", image, "seed", resolved.Config.Seed)
+	renderJobOrExit(resolved)
+}
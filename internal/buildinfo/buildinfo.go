@@ -0,0 +1,34 @@
+// Package buildinfo names the running build, for output metadata and for checking that
+// the processes of a cluster run the same one.
+package buildinfo
+
+import "runtime/debug"
+
+// Version names this build, with its VCS revision when the binary carries one
+func Version() string {
+	version := "algebraic_vis"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = "+dirty"
+			}
+		}
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version += " " + info.Main.Version // Pseudo-versions already name the revision
+	} else if revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		version += " rev " + revision + modified
+	}
+	return version + " (" + info.GoVersion + ")"
+}
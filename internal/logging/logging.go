@@ -0,0 +1,26 @@
+// Package logging gives the library packages their logger: the one they were handed, or
+// one that drops everything, so that they never write to slog's default.
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler drops every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discard = slog.New(discardHandler{})
+
+// Or returns logger, or a logger that drops everything if it is nil
+func Or(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discard
+	}
+	return logger
+}
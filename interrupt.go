@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// An interrupt asks a run to stop taking work and write what it has finished. Each stage
+// (enumeration, video frames, tiles) stops at the first SIGINT or SIGTERM and hands its
+// results on; a stage that starts after the interrupt runs to completion, since it is
+// writing the partial output. A second Ctrl-C ends the process at once.
+
+// interruptContext returns a context cancelled by the first SIGINT or SIGTERM.
+// Call stop when the run is over.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			// From now on the signals kill the process as usual
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			slog.Warn("Interrupted: wrapping up (press Ctrl-C again to quit)")
+			cancel()
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// afterInterrupt returns ctx, or a context that is never cancelled if ctx already was,
+// for a stage that writes the output of an interrupted run
+func afterInterrupt(ctx context.Context) context.Context {
+	if ctx.Err() != nil {
+		return context.WithoutCancel(ctx)
+	}
+	return ctx
+}
+
+// exitIfInterrupted ends a run that was interrupted, now that its partial output is written,
+// with the status shells use for Ctrl-C
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		stopProfiling()
+		os.Exit(130)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Status messages go through log/slog to stderr, leaving stdout to the output proper: help,
+// info listings and the image itself with --output -. setup replaces the default logger,
+// which the commands hand to the libraries as Options.Logger and Config.Logger; without one
+// the libraries log nothing.
+
+// logFlags are the logging flags every command takes. Like the profiling flags they are
+// not settings of a job.
+type logFlags struct {
+	quiet  *bool
+	format *string
+}
+
+// addLogFlags defines the logging flags on fs
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	l := &logFlags{
+		quiet:  fs.Bool("quiet", false, "Log only warnings and errors"),
+		format: fs.String("log-format", "text", "Log format: text or json"),
+	}
+	fs.BoolVar(l.quiet, "q", false, "Log only warnings and errors")
+	return l
+}
+
+// printLogUsage prints the help lines of the logging flags
+func printLogUsage() {
+	fmt.Printf("  --quiet, -q       Log only warnings and errors\n")
+	fmt.Printf("  --log-format FMT  Log messages on stderr as text or json, one object per line (default: text)\n")
+}
+
+// setup installs the logger the flags ask for
+func (l *logFlags) setup() error {
+	level := slog.LevelInfo
+	if *l.quiet {
+		level = slog.LevelWarn
+	}
+	switch *l.format {
+	case "text":
+		slog.SetDefault(slog.New(newCLIHandler(os.Stderr, level)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", *l.format)
+	}
+	return nil
+}
+
+// fatalf logs an error, writes out any profiles and exits with status 1
+func fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	stopProfiling()
+	os.Exit(1)
+}
+
+// cliHandler writes records for people: the message, then key=value pairs, with warnings
+// and errors marked but no time or level otherwise
+type cliHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Level
+	attrs string // Preformatted by WithAttrs
+	group string // Prefix of the keys, from WithGroup
+}
+
+func newCLIHandler(w io.Writer, level slog.Level) *cliHandler {
+	return &cliHandler{w: w, mu: new(sync.Mutex), level: level}
+}
+
+func (h *cliHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *cliHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("Warning: ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *cliHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *cliHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+// appendAttr writes a as " key=value", quoting values that would not read back as one word
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			appendAttr(b, prefix, ga)
+		}
+		return
+	}
+	s := v.String()
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		s = strconv.Quote(s)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, s)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+)
+
+// printMergeUsage prints the merge subcommand help
+func printMergeUsage(progName string) {
+	fmt.Printf("Usage: %s merge [flags] SHARD.points...\n", progName)
+	fmt.Printf("  Renders an enumeration that was split across processes or machines with --shard K/N.\n")
+	fmt.Printf("  Give the point file of every shard; the result is identical to a single run with the\n")
+	fmt.Printf("  same seed. The seed, family and max-height come from the shards.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  Any render, logging or profiling flag of %s itself, e.g. --output, --width, --palette or --center.\n", progName)
+	fmt.Printf("  Choose the view with --center/--span/--zoom or --preset, since the arguments are files.\n")
+	fmt.Printf("  --max-height may be lowered to render only the smaller heights.\n")
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s --max-height 26 --seed 42 --shard 1/3     # on each of three machines, K = 1, 2, 3\n", progName)
+	fmt.Printf("  %s merge --output h26.png shard-1-of-3.points shard-2-of-3.points shard-3-of-3.points\n", progName)
+}
+
+// runMerge implements the merge subcommand
+func runMerge(progName string, args []string) {
+	rf := newRenderFlags("merge", flag.ExitOnError)
+	lf := addLogFlags(rf.fs)
+	pf := addProfileFlags(rf.fs)
+	rf.fs.Usage = func() {
+		printMergeUsage(progName)
+	}
+	rf.fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *rf.help || *rf.helpLong {
+		printMergeUsage(progName)
+		return
+	}
+	if rf.fs.NArg() == 0 {
+		rf.fs.Usage()
+		os.Exit(1)
+	}
+	if *rf.shard != "" || *rf.checkpoint != "" || *rf.resume {
+		fatalf("merge reads finished shards; --shard, --checkpoint and --resume do not apply")
+	}
+
+	sources, err := enumerate.OpenPointFiles(rf.fs.Args(), slog.Default())
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer func() {
+		for _, s := range sources {
+			s.Close()
+		}
+	}()
+
+	// The shards fix the seed and family and bound the height
+	header := sources[0].Header
+	given := make(map[string]bool)
+	rf.fs.Visit(func(fl *flag.Flag) {
+		given[fl.Name] = true
+	})
+	if given["seed"] && *rf.seed != header.Seed {
+		fatalf("the shards were made with seed %d, not %d", header.Seed, *rf.seed)
+	}
+	rf.fs.Set("seed", fmt.Sprint(header.Seed))
+	if given["family"] {
+		if family, err := enumerate.ParseFamily(*rf.family); err == nil && family != header.Family {
+			fatalf("the shards enumerate %s polynomials, not %s", header.Family, family)
+		}
+	}
+	rf.fs.Set("family", header.Family.String())
+	if !given["max-height"] {
+		rf.fs.Set("max-height", fmt.Sprint(header.MaxHeight))
+	} else if *rf.maxHeight > header.MaxHeight {
+		fatalf("the shards only go up to height %d", header.MaxHeight)
+	}
+
+	job, err := rf.resolve(nil)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+	renderFromOrExit(job, func(context.Context) ([]enumerate.Point, enumerate.Enumeration, error) {
+		return enumerate.Merge(sources, job.Config.MaxHeight, slog.Default())
+	})
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/render"
+	"github.com/jayalane/algebraic_vis/roots"
+	"github.com/jayalane/algebraic_vis/video"
+)
+
+// renderFlags are the flags of a render command line, defined on their own FlagSet so that
+// main and each batch job parse them alike
+type renderFlags struct {
+	fs *flag.FlagSet
+
+	maxHeight       *int
+	seed            *int64
+	family          *string
+	checkpoint      *string
+	checkpointEvery *time.Duration
+	resume          *bool
+	shard           *string
+	progress        *string
+	videoMode       *bool
+	frameRate       *int
+	outputFile      *string
+	colorBy         *string
+	vectorMaxPoints *int
+	width           *int
+	height          *int
+	dpi             *float64
+	aspect          *string
+	center          *string
+	span            *float64
+	zoom            *float64
+	preset          *string
+	presetsFile     *string
+	listPresets     *bool
+	tiled           *string
+	tileSize        *int
+	overlayList     *string
+	caption         *string
+	captionPos      *string
+	fontSize        *int
+	textColor       *string
+	textBackground  *string
+	paletteSpec     *string
+	highlight       *string
+	smallMeasure    *float64
+	highlightList   *string
+	configFile      *string
+	dumpConfig      *string
+	help            *bool
+	helpLong        *bool
+	resources       *resourceFlags
+
+	// Settings applied like a job file beneath --config, e.g. the job embedded in an image
+	baseSource   string
+	baseSettings []configSetting
+}
+
+// newRenderFlags defines the render flags on a new FlagSet
+func newRenderFlags(name string, handling flag.ErrorHandling) *renderFlags {
+	fs := flag.NewFlagSet(name, handling)
+	return &renderFlags{
+		fs:              fs,
+		maxHeight:       fs.Int("max-height", 15, "Maximum polynomial height (complexity). Higher = more detail but slower"),
+		seed:            fs.Int64("seed", 0, "Seed for the root finder; the same seed reproduces an image exactly (0 = random)"),
+		family:          fs.String("family", "all", "Polynomials to enumerate: "+enumerate.FamilyList),
+		checkpoint:      fs.String("checkpoint", "", "Save enumeration progress to this file (and FILE.points)"),
+		checkpointEvery: fs.Duration("checkpoint-every", 5*time.Minute, "Interval between checkpoint saves"),
+		resume:          fs.Bool("resume", false, "Continue the enumeration saved in --checkpoint"),
+		shard:           fs.String("shard", "", "Solve only part K/N of the enumeration and save it as a point file for merge"),
+		progress:        fs.String("progress", "text", "Enumeration progress on stderr: "+progressModes),
+		videoMode:       fs.Bool("video", false, "Generate animation showing heights 2 to max-height (requires ffmpeg)"),
+		frameRate:       fs.Int("fps", 2, "Frame rate for video mode"),
+		outputFile:      fs.String("output", "", "Output filename, or - for a PNG on stdout (default: algebraic_numbers.png or .mp4 for video)"),
+		colorBy:         fs.String("color-by", "leading", "Coloring rule: "+render.ColorSchemeList()),
+		vectorMaxPoints: fs.Int("vector-max-points", 20000, "Draw SVG/PDF output as density contours above this many points (0 = never)"),
+		width:           fs.Int("width", 1200, "Image width in pixels"),
+		height:          fs.Int("height", 800, "Image height in pixels"),
+		dpi:             fs.Float64("dpi", 0, "Print resolution recorded in the output (0 = unset)"),
+		aspect:          fs.String("aspect", "pad", "Match the viewport to the image shape: "+render.AspectModes),
+		center:          fs.String("center", "", "Center of the view, e.g. -0.5+0.866i"),
+		span:            fs.Float64("span", 0, "Width of the view in the complex plane"),
+		zoom:            fs.Float64("zoom", 0, "Magnify the view about its center"),
+		preset:          fs.String("preset", "", "Named view (see --list-presets)"),
+		presetsFile:     fs.String("presets", "", "Extra presets file (JSON)"),
+		listPresets:     fs.Bool("list-presets", false, "List the named views and exit"),
+		tiled:           fs.String("tiled", "", "Render a WxH image tile by tile (streamed .png, or a directory of tiles)"),
+		tileSize:        fs.Int("tile-size", 4096, "Tile edge in pixels for --tiled"),
+		overlayList:     fs.String("overlay", "", "Comma-separated annotations: "+render.OverlayNames),
+		caption:         fs.String("caption", "", "Caption text (implies --overlay caption)"),
+		captionPos:      fs.String("caption-pos", "bottom-left", "Caption position"),
+		fontSize:        fs.Int("font-size", 0, "Pixels per font dot for overlay text (0 = automatic)"),
+		textColor:       fs.String("text-color", "#ffffff", "Overlay text color"),
+		textBackground:  fs.String("text-bg", "#000000b4", "Overlay text background: #rrggbb, #rrggbbaa or none"),
+		paletteSpec:     fs.String("palette", "", "Palette: "+render.PaletteList()+", or a .json/.gpl file"),
+		highlight:       fs.String("highlight", "", "Comma-separated special numbers to mark: "+highlightList),
+		smallMeasure:    fs.Float64("small-measure", enumerate.DefaultSmallMeasure, "Mahler measures below this count as small for --highlight"),
+		highlightList:   fs.String("highlight-list", "", "Write the ranked Pisot, Salem, small-measure and cyclotomic polynomials to a .csv or .json file"),
+		configFile:      fs.String("config", "", "Job file (.toml, .yaml or .json) supplying defaults for these flags"),
+		dumpConfig:      fs.String("dump-config", "", "Write the effective configuration to a job file"),
+		help:            fs.Bool("h", false, "Show help message"),
+		helpLong:        fs.Bool("help", false, "Show help message"),
+		resources:       addResourceFlags(fs),
+	}
+}
+
+// renderJob is a fully resolved render command line
+type renderJob struct {
+	Config     render.Config
+	Tiled      bool // Render tile by tile; Config.Width x Config.Height came from --tiled
+	TileSize   int
+	Family     enumerate.Family // Polynomials to enumerate
+	Checkpoint enumerate.CheckpointOptions
+	Shard      int // With --shard K/N, K and N; the job writes a point file instead of rendering
+	Shards     int
+
+	Highlight     map[roots.Kind]bool // Kinds of special numbers to mark
+	SmallMeasure  float64             // Bound of a small Mahler measure
+	HighlightList string              // Ranked list to write, if any
+
+	flags *renderFlags // For --dump-config
+}
+
+// resolve applies the job file, checks the parsed flags and builds the job they describe.
+// args are the positional viewport corners, if any.
+func (f *renderFlags) resolve(args []string) (renderJob, error) {
+	// A job file fills in whatever the command line left unset
+	if *f.configFile != "" {
+		corners, err := applyConfigFile(*f.configFile, f.fs, len(args) > 0)
+		if err != nil {
+			return renderJob{}, err
+		}
+		if len(args) == 0 {
+			args = corners
+		}
+	}
+	if f.baseSettings != nil {
+		corners, err := applyConfigSettings(f.baseSource, f.baseSettings, f.fs, len(args) > 0)
+		if err != nil {
+			return renderJob{}, err
+		}
+		if len(args) == 0 {
+			args = corners
+		}
+	}
+	// A resumed enumeration keeps its seed and family
+	if *f.resume {
+		if *f.checkpoint == "" {
+			return renderJob{}, fmt.Errorf("--resume needs --checkpoint")
+		}
+		saved, err := enumerate.ReadCheckpoint(*f.checkpoint)
+		if err != nil {
+			return renderJob{}, fmt.Errorf("failed to read checkpoint: %v", err)
+		}
+		if *f.seed != 0 && *f.seed != saved.Seed {
+			return renderJob{}, fmt.Errorf("checkpoint %s was made with seed %d, not %d", *f.checkpoint, saved.Seed, *f.seed)
+		}
+		*f.seed = saved.Seed
+		familyGiven := false
+		f.fs.Visit(func(fl *flag.Flag) {
+			familyGiven = familyGiven || fl.Name == "family"
+		})
+		if family, err := enumerate.ParseFamily(*f.family); err == nil && familyGiven && family != saved.Family {
+			return renderJob{}, fmt.Errorf("checkpoint %s enumerates %s polynomials, not %s", *f.checkpoint, saved.Family, family)
+		}
+		*f.family = saved.Family.String()
+	}
+	if *f.seed == 0 {
+		*f.seed = enumerate.NewSeed()
+	}
+
+	var shard, shards int
+	if *f.shard != "" {
+		var err error
+		if shard, shards, err = parseShard(*f.shard); err != nil {
+			return renderJob{}, err
+		}
+	}
+	if err := checkProgressMode(*f.progress); err != nil {
+		return renderJob{}, err
+	}
+	if err := f.resources.apply(); err != nil {
+		return renderJob{}, err
+	}
+	if *f.outputFile == "-" && (*f.videoMode || *f.tiled != "" || shards > 0) {
+		return renderJob{}, fmt.Errorf("--output - writes a PNG to stdout, which --video, --tiled and --shard cannot")
+	}
+
+	// Set default output filename based on mode
+	defaultOutput := "algebraic_numbers.png"
+	if *f.videoMode {
+		defaultOutput = "algebraic_numbers.mp4"
+	} else if shards > 0 {
+		defaultOutput = fmt.Sprintf("shard-%d-of-%d.points", shard, shards)
+	}
+	if *f.outputFile == "" {
+		*f.outputFile = defaultOutput
+	}
+
+	config := render.Config{
+		Width:           *f.width,
+		Height:          *f.height,
+		XMin:            -2.0,
+		YMin:            -2.0,
+		XMax:            2.0,
+		YMax:            2.0,
+		MaxHeight:       *f.maxHeight,
+		OutputFile:      *f.outputFile,
+		VideoMode:       *f.videoMode,
+		FrameRate:       *f.frameRate,
+		ColorBy:         *f.colorBy,
+		VectorMaxPoints: *f.vectorMaxPoints,
+		DPI:             *f.dpi,
+		Seed:            *f.seed,
+		Logger:          slog.Default(),
+	}
+	job := renderJob{Tiled: *f.tiled != "", TileSize: *f.tileSize, flags: f}
+	job.Checkpoint = enumerate.CheckpointOptions{Path: *f.checkpoint, Every: *f.checkpointEvery, Resume: *f.resume}
+	job.Shard, job.Shards = shard, shards
+	family, err := enumerate.ParseFamily(*f.family)
+	if err != nil {
+		return job, err
+	}
+	job.Family = family
+	if job.Highlight, err = parseHighlight(*f.highlight); err != nil {
+		return job, err
+	}
+	job.SmallMeasure, job.HighlightList = *f.smallMeasure, *f.highlightList
+
+	// Check remaining positional arguments for viewport
+	if len(args) != 0 && len(args) != 4 {
+		return job, fmt.Errorf("wrong number of positional arguments (want x_min y_min x_max y_max)")
+	}
+
+	// Validate parameters
+	if *f.maxHeight < 2 {
+		return job, fmt.Errorf("max-height must be at least 2")
+	}
+	if *f.checkpointEvery <= 0 {
+		return job, fmt.Errorf("checkpoint-every must be positive")
+	}
+	if shards > 0 {
+		if *f.videoMode || job.Tiled {
+			return job, fmt.Errorf("a shard only computes points; render them with merge")
+		}
+		if !strings.EqualFold(filepath.Ext(*f.outputFile), ".points") {
+			return job, fmt.Errorf("a shard writes a .points file, not %s", *f.outputFile)
+		}
+	}
+	if *f.smallMeasure <= 1 {
+		return job, fmt.Errorf("small-measure must be above 1")
+	}
+	if ext := strings.ToLower(filepath.Ext(*f.highlightList)); *f.highlightList != "" && ext != ".csv" && ext != ".json" {
+		return job, fmt.Errorf("highlight-list writes a .csv or .json file, not %s", *f.highlightList)
+	}
+	if *f.frameRate < 1 || *f.frameRate > 60 {
+		return job, fmt.Errorf("fps must be between 1 and 60")
+	}
+	if ext := strings.ToLower(filepath.Ext(*f.outputFile)); *f.videoMode && (ext == ".svg" || ext == ".pdf") {
+		return job, fmt.Errorf("video mode cannot write %s output", ext)
+	}
+	if *f.width < 1 || *f.height < 1 {
+		return job, fmt.Errorf("width and height must be at least 1")
+	}
+	if *f.dpi < 0 {
+		return job, fmt.Errorf("dpi cannot be negative")
+	}
+	if *f.videoMode && (*f.width%2 != 0 || *f.height%2 != 0) {
+		return job, fmt.Errorf("video mode needs an even width and height")
+	}
+	if job.Tiled {
+		if *f.videoMode {
+			return job, fmt.Errorf("--tiled cannot be combined with --video")
+		}
+		if ext := strings.ToLower(filepath.Ext(*f.outputFile)); ext == ".svg" || ext == ".pdf" {
+			return job, fmt.Errorf("--tiled cannot write %s output", ext)
+		}
+		if *f.tileSize < 16 {
+			return job, fmt.Errorf("tile-size must be at least 16")
+		}
+		w, h, err := render.ParseSize(*f.tiled)
+		if err != nil {
+			return job, err
+		}
+		var conflict string
+		f.fs.Visit(func(fl *flag.Flag) {
+			if (fl.Name == "width" && *f.width != w) || (fl.Name == "height" && *f.height != h) {
+				conflict = fl.Name
+			}
+		})
+		if conflict != "" {
+			return job, fmt.Errorf("--tiled sets the image size; drop --%s", conflict)
+		}
+		config.Width, config.Height = w, h
+	}
+
+	presets, err := render.LoadPresets(*f.presetsFile)
+	if err != nil {
+		return job, err
+	}
+	view := render.ViewOptions{Center: *f.center, Span: *f.span, Zoom: *f.zoom, Preset: *f.preset}
+	if err := render.ApplyView(&config, args, view, presets); err != nil {
+		return job, err
+	}
+	if config, err = render.FitAspect(config, *f.aspect); err != nil {
+		return job, err
+	}
+
+	if _, ok := render.ColorSchemes[*f.colorBy]; !ok {
+		return job, fmt.Errorf("unknown color-by rule %q (choose from %s)", *f.colorBy, render.ColorSchemeList())
+	}
+	if *f.paletteSpec != "" {
+		if config.Palette, err = render.LoadPalette(*f.paletteSpec); err != nil {
+			return job, err
+		}
+	}
+	if config.Overlays, err = render.ParseOverlays(*f.overlayList); err != nil {
+		return job, err
+	}
+	if *f.caption != "" {
+		config.Overlays.Caption = true
+		config.Caption = *f.caption
+	}
+	if ext := strings.ToLower(filepath.Ext(*f.outputFile)); (ext == ".svg" || ext == ".pdf") &&
+		(config.Overlays.Legend || config.Overlays.Axes || config.Overlays.Caption) {
+		return job, fmt.Errorf("%s output draws only the unit-circle overlay; drop legend, axes, caption and --caption", ext)
+	}
+	if config.CaptionAnchor, err = render.ParseAnchor(*f.captionPos); err != nil {
+		return job, err
+	}
+	config.Text = render.DefaultTextStyle
+	config.Text.Scale = *f.fontSize
+	if config.Text.Color, err = render.ParseHexColor(*f.textColor); err != nil {
+		return job, fmt.Errorf("text-color: %v", err)
+	}
+	if config.Text.Background, err = render.ParseBackground(*f.textBackground); err != nil {
+		return job, fmt.Errorf("text-bg: %v", err)
+	}
+
+	job.Config = config
+	return job, nil
+}
+
+// parseShard parses a --shard value K/N
+func parseShard(s string) (shard, shards int, err error) {
+	k, n, ok := strings.Cut(s, "/")
+	if ok {
+		shard, err = strconv.Atoi(k)
+		if err == nil {
+			shards, err = strconv.Atoi(n)
+		}
+	}
+	if !ok || err != nil || shards < 1 || shard < 1 || shard > shards {
+		return 0, 0, fmt.Errorf("invalid shard %q (want K/N with 1 <= K <= N)", s)
+	}
+	return shard, shards, nil
+}
+
+// enumerate finds the job's points, checkpointing them or saving them as a shard as asked
+func (j renderJob) enumerate(ctx context.Context) ([]enumerate.Point, enumerate.Enumeration, error) {
+	slog.Info("Calculating algebraic numbers")
+	want := enumerate.PointFileHeader{Seed: j.Config.Seed, MaxHeight: j.Config.MaxHeight, Shard: 1, Shards: 1, Family: j.Family}
+	if j.Shards > 0 {
+		want.Shard, want.Shards = j.Shard, j.Shards
+	}
+	opts := enumerate.Options{Shard: want.Shard, Shards: want.Shards, Family: j.Family, Logger: slog.Default()}
+	opts.Progress = progressReporter(*j.flags.progress)
+	opts.Workers = *j.flags.resources.workers
+	if j.Checkpoint.Path != "" {
+		cp, err := enumerate.OpenCheckpoint(j.Checkpoint, want)
+		if err != nil {
+			return nil, enumerate.Enumeration{}, err
+		}
+		opts.Checkpoint = cp
+	} else if j.Shards > 0 {
+		pw, err := enumerate.CreatePointFile(j.Config.OutputFile, want)
+		if err != nil {
+			return nil, enumerate.Enumeration{}, err
+		}
+		opts.Output = pw
+	}
+
+	points, enum, err := enumerate.Run(ctx, j.Config.MaxHeight, j.Config.Seed, opts)
+	if err == nil && opts.Checkpoint != nil && j.Shards > 0 {
+		err = opts.Checkpoint.ExportPoints(j.Config.OutputFile)
+	}
+	return points, enum, err
+}
+
+// dumpConfig writes the job's effective settings if --dump-config was given
+func (j renderJob) dumpConfig() error {
+	path := *j.flags.dumpConfig
+	if path == "" {
+		return nil
+	}
+	if err := writeConfigFile(path, j.flags.fs, j.Config); err != nil {
+		return err
+	}
+	slog.Info("Saved effective configuration", "path", path)
+	return nil
+}
+
+// seedGiven says whether the command line or job file chose the seed
+func (j renderJob) seedGiven() bool {
+	given := false
+	j.flags.fs.Visit(func(fl *flag.Flag) {
+		given = given || fl.Name == "seed"
+	})
+	return given
+}
+
+// setSeed replaces the seed the job was resolved with
+func (j *renderJob) setSeed(seed int64) {
+	j.Config.Seed = seed
+	*j.flags.seed = seed
+}
+
+// run renders the job from points, which must include every point up to Config.MaxHeight,
+// in the order enum produced them. Cancelling ctx stops videos and tiled renders early,
+// leaving partial output.
+func (j renderJob) run(ctx context.Context, points []enumerate.Point, enum enumerate.Enumeration) error {
+	jobFile, err := formatConfigFile("job.toml", j.flags.fs, j.Config)
+	if err != nil {
+		return err
+	}
+	enum = enum.UpTo(j.Config.MaxHeight, points)
+	j.Config.Run = &render.RunInfo{
+		Enumeration: enum,
+		Job:         string(jobFile),
+		Start:       time.Now(),
+		StoppedAt:   enum.StoppedAt,
+	}
+	if err := j.highlight(points); err != nil {
+		return err
+	}
+
+	switch {
+	case j.Config.VideoMode:
+		return video.Generate(ctx, points, j.Config)
+	case j.Tiled && strings.EqualFold(filepath.Ext(j.Config.OutputFile), ".png"):
+		return render.TiledPNG(ctx, points, j.Config, j.TileSize)
+	case j.Tiled:
+		return render.TiledDir(ctx, points, j.Config, j.TileSize)
+	case j.Config.OutputFile == "-":
+		if err := render.PNG(os.Stdout, points, j.Config); err != nil {
+			return err
+		}
+		slog.Info("Wrote image to stdout")
+		return nil
+	default:
+		return render.WriteFile(points, j.Config)
+	}
+}
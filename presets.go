@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/jayalane/algebraic_vis/render"
+)
+
+// printPresets lists presets for --list-presets
+func printPresets(presets map[string]render.Preset) {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := presets[name]
+		fmt.Printf("  %-16s center %-20s span %-8s %s\n", name, p.Center, strconv.FormatFloat(p.Span, 'g', 6, 64), p.Description)
+	}
+	if path := render.UserPresetsFile(); path != "" {
+		fmt.Printf("\nAdd your own in %s\n", path)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	pprofile "runtime/pprof"
+	"runtime/trace"
+	"strings"
+)
+
+// profileFlags are the profiling flags of the commands that enumerate or render. They are
+// defined beside the render flags but are not settings of a job.
+type profileFlags struct {
+	cpuProfile *string
+	memProfile *string
+	trace      *string
+	pprofAddr  *string
+}
+
+// addProfileFlags defines the profiling flags on fs
+func addProfileFlags(fs *flag.FlagSet) *profileFlags {
+	return &profileFlags{
+		cpuProfile: fs.String("cpuprofile", "", "Write a CPU profile to this file"),
+		memProfile: fs.String("memprofile", "", "Write a heap profile to this file when the run ends"),
+		trace:      fs.String("trace", "", "Write an execution trace to this file"),
+		pprofAddr:  fs.String("pprof", "", "Serve live profiles over HTTP at this address"),
+	}
+}
+
+// printProfileUsage prints the help lines of the profiling flags
+func printProfileUsage() {
+	fmt.Printf("  --cpuprofile FILE Write a CPU profile to FILE, for go tool pprof\n")
+	fmt.Printf("  --memprofile FILE Write a heap profile to FILE when the run ends\n")
+	fmt.Printf("  --trace FILE      Write an execution trace to FILE, for go tool trace\n")
+	fmt.Printf("  --pprof ADDR      Serve live profiles at http://ADDR/debug/pprof/ during the run\n")
+}
+
+// profileStops finish the profiles started by start, last first
+var profileStops []func()
+
+// start begins the profiles asked for. Call stopProfiling when the run is over; an
+// interrupted run stops them in exitIfInterrupted.
+func (p *profileFlags) start() error {
+	if *p.pprofAddr != "" {
+		ln, err := net.Listen("tcp", *p.pprofAddr)
+		if err != nil {
+			return fmt.Errorf("pprof: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go http.Serve(ln, mux)
+		addr := *p.pprofAddr
+		if strings.HasSuffix(addr, ":0") {
+			addr = ln.Addr().String() // The port picked
+		}
+		slog.Info("Serving profiles at http://" + displayAddr(addr) + "/debug/pprof/")
+	}
+	if *p.cpuProfile != "" {
+		file, err := os.Create(*p.cpuProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %v", err)
+		}
+		if err := pprofile.StartCPUProfile(file); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to start CPU profile: %v", err)
+		}
+		profileStops = append(profileStops, func() {
+			pprofile.StopCPUProfile()
+			closeProfile(file, "CPU profile")
+		})
+	}
+	if *p.trace != "" {
+		file, err := os.Create(*p.trace)
+		if err != nil {
+			return fmt.Errorf("failed to create trace: %v", err)
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to start trace: %v", err)
+		}
+		profileStops = append(profileStops, func() {
+			trace.Stop()
+			closeProfile(file, "trace")
+		})
+	}
+	if path := *p.memProfile; path != "" {
+		profileStops = append(profileStops, func() {
+			file, err := os.Create(path)
+			if err != nil {
+				slog.Error("Failed to create heap profile: " + err.Error())
+				return
+			}
+			runtime.GC() // Up-to-date statistics
+			if err := pprofile.WriteHeapProfile(file); err != nil {
+				slog.Error("Failed to write heap profile: " + err.Error())
+			}
+			closeProfile(file, "heap profile")
+		})
+	}
+	return nil
+}
+
+// stopProfiling writes out the profiles started by start
+func stopProfiling() {
+	for i := len(profileStops) - 1; i >= 0; i-- {
+		profileStops[i]()
+	}
+	profileStops = nil
+}
+
+// closeProfile closes a finished profile and says where it is
+func closeProfile(file *os.File, what string) {
+	if err := file.Close(); err != nil {
+		slog.Error(fmt.Sprintf("Failed to write %s: %v", what, err))
+		return
+	}
+	slog.Info("Wrote "+what, "path", file.Name())
+}
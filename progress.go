@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+)
+
+// progressModes are the values of --progress
+const progressModes = "text, json or none"
+
+// textProgressEvery spaces out text reports, which are meant for people
+const textProgressEvery = 10 * time.Second
+
+// checkProgressMode rejects an unknown --progress value
+func checkProgressMode(mode string) error {
+	switch mode {
+	case "text", "json", "none":
+		return nil
+	}
+	return fmt.Errorf("unknown progress mode %q (want %s)", mode, progressModes)
+}
+
+// printProgressUsage prints the help line of --progress
+func printProgressUsage() {
+	fmt.Printf("  --progress MODE   Enumeration progress on stderr: text, json (one object per line) or none (default: text)\n")
+}
+
+// progressReporter returns the enumeration progress callback for a --progress mode. JSON
+// reports go to stderr, and text ones are logged, so that --quiet drops them.
+func progressReporter(mode string) func(enumerate.Progress) {
+	switch mode {
+	case "json":
+		enc := json.NewEncoder(os.Stderr)
+		return func(p enumerate.Progress) { enc.Encode(newProgressLine(p)) }
+	case "text":
+		var last time.Duration
+		return func(p enumerate.Progress) {
+			// The summary that follows a run stands in for the last report
+			if !p.Finished && p.Elapsed-last >= textProgressEvery {
+				last = p.Elapsed
+				printProgress(p)
+			}
+		}
+	}
+	return nil
+}
+
+// printProgress logs a progress report as a line of text
+func printProgress(p enumerate.Progress) {
+	eta := "unknown"
+	if p.ETA >= 0 {
+		eta = p.ETA.Round(time.Second).String()
+	}
+	slog.Info(fmt.Sprintf("Height %d/%d: %.0f%% of height, %.1f%% overall, %.0f polys/s, %d roots, ETA %s",
+		p.Height, p.MaxHeight, percent(p.HeightDone, p.HeightTotal), percent(p.Done, p.Total), p.Rate, p.Roots, eta))
+}
+
+// percent returns part as a percentage of whole
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 100
+	}
+	return 100 * float64(part) / float64(whole)
+}
+
+// progressLine is a progress report as --progress=json writes it, one object per line
+type progressLine struct {
+	Height      int      `json:"height"`
+	MaxHeight   int      `json:"max_height"`
+	HeightDone  int      `json:"height_done"`
+	HeightTotal int      `json:"height_total"`
+	Done        int      `json:"done"`
+	Total       int      `json:"total"`
+	Solved      int      `json:"solved"`
+	Roots       int      `json:"roots"`
+	Rate        float64  `json:"polys_per_second"`
+	Elapsed     float64  `json:"elapsed_seconds"`
+	ETA         *float64 `json:"eta_seconds"` // null while unknown
+	Finished    bool     `json:"finished"`
+}
+
+// newProgressLine converts a progress report for --progress=json, giving durations in
+// seconds and a null ETA while it is unknown
+func newProgressLine(p enumerate.Progress) progressLine {
+	line := progressLine{
+		Height: p.Height, MaxHeight: p.MaxHeight, HeightDone: p.HeightDone, HeightTotal: p.HeightTotal,
+		Done: p.Done, Total: p.Total, Solved: p.Solved, Roots: p.Roots,
+		Rate: p.Rate, Elapsed: p.Elapsed.Seconds(), Finished: p.Finished,
+	}
+	if p.ETA >= 0 {
+		eta := p.ETA.Seconds()
+		line.ETA = &eta
+	}
+	return line
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/render"
+)
+
+// squareConfig validates the flags shared by the tile subcommands and builds their Config,
+// with the viewport from the positional arguments widened to a square
+func squareConfig(args []string, maxHeight int, colorBy, paletteSpec string, unitCircle bool, usage func()) render.Config {
+	config := render.Config{
+		XMin:      -2.0,
+		YMin:      -2.0,
+		XMax:      2.0,
+		YMax:      2.0,
+		MaxHeight: maxHeight,
+		ColorBy:   colorBy,
+		Overlays:  render.Overlays{UnitCircle: unitCircle},
+		Logger:    slog.Default(),
+	}
+	if len(args) == 4 {
+		if err := render.ParseViewport(args, &config); err != nil {
+			fatalf("%v", err)
+		}
+	} else if len(args) != 0 {
+		slog.Error("Wrong number of positional arguments")
+		usage()
+		os.Exit(1)
+	}
+
+	if maxHeight < 2 {
+		fatalf("max-height must be at least 2")
+	}
+	if _, ok := render.ColorSchemes[colorBy]; !ok {
+		fatalf("unknown color-by rule %q (choose from %s)", colorBy, render.ColorSchemeList())
+	}
+	if paletteSpec != "" {
+		palette, err := render.LoadPalette(paletteSpec)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		config.Palette = palette
+	}
+
+	if config.XMax-config.XMin != config.YMax-config.YMin {
+		config = render.PadViewport(config, 1)
+		slog.Info("Widening viewport to a square",
+			"x_min", config.XMin, "y_min", config.YMin, "x_max", config.XMax, "y_max", config.YMax)
+	}
+	return config
+}
+
+func printPyramidUsage(progName string) {
+	fmt.Printf("Usage: %s pyramid [flags] [x_min y_min x_max y_max]\n", progName)
+	fmt.Printf("  Writes a deep-zoom tile pyramid of the rectangle for OpenSeadragon, Leaflet and similar viewers.\n")
+	fmt.Printf("  A non-square rectangle is widened about its center to a square.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --max-height N    Maximum polynomial height (default: 12)\n")
+	fmt.Printf("  --family NAME     Polynomials to enumerate: %s (default: all)\n", enumerate.FamilyList)
+	fmt.Printf("  --seed N          Seed for the root finder; the same seed reproduces a pyramid exactly (default: random)\n")
+	fmt.Printf("  --max-zoom N      Deepest zoom level; level z is 2^z x 2^z tiles of %d pixels (default: 5)\n", render.PyramidTileSize)
+	fmt.Printf("  --output DIR      Output directory (default: algebraic_pyramid)\n")
+	fmt.Printf("  --color-by RULE   Coloring rule: %s (default: leading)\n", render.ColorSchemeList())
+	fmt.Printf("  --palette NAME    Palette: %s, or a .json/.gpl file\n", render.PaletteList())
+	fmt.Printf("  --unit-circle     Draw the circle |z| = 1\n")
+	printProgressUsage()
+	printResourceUsage()
+	printLogUsage()
+	printProfileUsage()
+	fmt.Printf("\nOutput:\n")
+	fmt.Printf("  DIR/{z}/{x}/{y}.png          XYZ tiles, y counted down from the top\n")
+	fmt.Printf("  DIR/pyramid.dzi              Deep Zoom descriptor, tiles in DIR/pyramid_files\n")
+	fmt.Printf("  DIR/pyramid.json             Zoom range, complex-plane bounds and seed\n")
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s pyramid --max-height 14 --max-zoom 6\n", progName)
+	fmt.Printf("  %s pyramid --max-zoom 8 --output unit_disk -- -1 -1 1 1\n", progName)
+}
+
+// runPyramid implements the pyramid subcommand
+func runPyramid(progName string, args []string) {
+	fs := flag.NewFlagSet("pyramid", flag.ExitOnError)
+	maxHeight := fs.Int("max-height", 12, "Maximum polynomial height")
+	familyName := fs.String("family", "all", "Polynomials to enumerate: "+enumerate.FamilyList)
+	seed := fs.Int64("seed", 0, "Seed for the root finder; the same seed reproduces a pyramid exactly (0 = random)")
+	maxZoom := fs.Int("max-zoom", 5, "Deepest zoom level")
+	outputDir := fs.String("output", "algebraic_pyramid", "Output directory")
+	colorBy := fs.String("color-by", "leading", "Coloring rule: "+render.ColorSchemeList())
+	paletteSpec := fs.String("palette", "", "Palette: "+render.PaletteList()+", or a .json/.gpl file")
+	unitCircle := fs.Bool("unit-circle", false, "Draw the circle |z| = 1")
+	progress := fs.String("progress", "text", "Enumeration progress on stderr: "+progressModes)
+	rs := addResourceFlags(fs)
+	lf := addLogFlags(fs)
+	pf := addProfileFlags(fs)
+	fs.Usage = func() {
+		printPyramidUsage(progName)
+	}
+	fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *maxZoom < 0 || *maxZoom > render.PyramidMaxZoom {
+		fatalf("max-zoom must be between 0 and %d", render.PyramidMaxZoom)
+	}
+	if err := checkProgressMode(*progress); err != nil {
+		fatalf("%v", err)
+	}
+	if err := rs.apply(); err != nil {
+		fatalf("%v", err)
+	}
+	family, err := enumerate.ParseFamily(*familyName)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	config := squareConfig(fs.Args(), *maxHeight, *colorBy, *paletteSpec, *unitCircle, fs.Usage)
+	config.OutputFile = *outputDir
+	if *seed == 0 {
+		*seed = enumerate.NewSeed()
+	}
+	config.Seed = *seed
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+
+	ctx, stop := interruptContext()
+	defer stop()
+	slog.Info("Calculating algebraic numbers", "seed", config.Seed)
+	opts := enumerate.Options{Progress: progressReporter(*progress), Workers: *rs.workers, Family: family, Logger: slog.Default()}
+	points, enum, err := enumerate.Run(ctx, config.MaxHeight, config.Seed, opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	config.Run = &render.RunInfo{Enumeration: enum, Start: time.Now(), StoppedAt: enum.StoppedAt} // Seed and solver in every tile
+	if err := render.Pyramid(afterInterrupt(ctx), points, config, *maxZoom); err != nil {
+		fatalf("Failed to render pyramid: %v", err)
+	}
+	exitIfInterrupted(ctx)
+}
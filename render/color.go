@@ -0,0 +1,229 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+)
+
+// Colorer assigns a blob color to each algebraic number
+type Colorer interface {
+	Color(p enumerate.Point) color.RGBA
+}
+
+// ColorerFunc adapts an ordinary function to the Colorer interface
+type ColorerFunc func(p enumerate.Point) color.RGBA
+
+// Color calls f(p)
+func (f ColorerFunc) Color(p enumerate.Point) color.RGBA {
+	return f(p)
+}
+
+// categorical returns the palette used by categorical rules
+func (c Config) categorical() *Palette {
+	if c.Palette != nil {
+		return c.Palette
+	}
+	return builtinPalettes["classic"]
+}
+
+// continuous returns the ramp used by continuous rules
+func (c Config) continuous() colorRamp {
+	if c.Palette != nil {
+		return c.Palette.ramp()
+	}
+	return defaultRamp
+}
+
+// colorRamp is a piecewise-linear gradient through evenly spaced color stops
+type colorRamp []color.RGBA
+
+// defaultRamp runs blue -> cyan -> green -> yellow -> red, low to high
+var defaultRamp = colorRamp{
+	{0, 0, 255, 255},
+	{0, 255, 255, 255},
+	{0, 255, 0, 255},
+	{255, 255, 0, 255},
+	{255, 0, 0, 255},
+}
+
+// at returns the ramp color at position t, clamped to [0, 1]
+func (r colorRamp) at(t float64) color.RGBA {
+	if len(r) == 1 || math.IsNaN(t) || t <= 0 {
+		return r[0]
+	}
+	if t >= 1 {
+		return r[len(r)-1]
+	}
+
+	pos := t * float64(len(r)-1)
+	i := int(pos)
+	frac := pos - float64(i)
+	a, b := r[i], r[i+1]
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*frac + 0.5)
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+}
+
+// LegendEntry is one swatch of a color legend
+type LegendEntry struct {
+	Label string
+	Color color.RGBA
+}
+
+// Legender is implemented by colorers that can describe their colors in a legend
+type Legender interface {
+	Legend() []LegendEntry
+}
+
+// categoryColorer colors each point by an integer class (1-based) looked up in a palette
+type categoryColorer struct {
+	class   func(p enumerate.Point) int
+	palette *Palette
+}
+
+// Color implements Colorer
+func (c categoryColorer) Color(p enumerate.Point) color.RGBA {
+	return c.palette.category(c.class(p))
+}
+
+// Legend implements Legender, one entry per palette class; the last class also covers higher values
+func (c categoryColorer) Legend() []LegendEntry {
+	n := len(c.palette.Colors)
+	if c.palette.Continuous {
+		n = categoryCount
+	}
+	entries := make([]LegendEntry, n)
+	for i := range entries {
+		entries[i] = LegendEntry{Label: strconv.Itoa(i + 1), Color: c.palette.category(i + 1)}
+	}
+	entries[n-1].Label += "+"
+	return entries
+}
+
+// rampColorer maps a continuous per-point quantity onto a color ramp over [lo, hi]
+type rampColorer struct {
+	value  func(p enumerate.Point) float64
+	lo, hi float64
+	ramp   colorRamp
+	label  func(v float64) string // Formats a value for the legend, undoing any log scaling
+}
+
+// Color implements Colorer
+func (c rampColorer) Color(p enumerate.Point) color.RGBA {
+	if c.hi <= c.lo {
+		return c.ramp.at(0)
+	}
+	return c.ramp.at((c.value(p) - c.lo) / (c.hi - c.lo))
+}
+
+// legendSteps is the number of labelled stops shown for a continuous rule
+const legendSteps = 5
+
+// Legend implements Legender with evenly spaced stops from lo to hi
+func (c rampColorer) Legend() []LegendEntry {
+	if c.hi < c.lo {
+		return nil // No points in view
+	}
+	entries := make([]LegendEntry, legendSteps)
+	for i := range entries {
+		t := float64(i) / float64(legendSteps-1)
+		entries[i] = LegendEntry{Label: c.label(c.lo + t*(c.hi-c.lo)), Color: c.ramp.at(t)}
+	}
+	return entries
+}
+
+// newDataRampColorer builds a rampColorer whose range spans the values of the points inside the viewport,
+// so zoomed views still use the whole ramp
+func newDataRampColorer(value func(p enumerate.Point) float64, label func(v float64) string, points []enumerate.Point, config Config) rampColorer {
+	c := rampColorer{value: value, lo: math.Inf(1), hi: math.Inf(-1), ramp: config.continuous(), label: label}
+	for _, p := range points {
+		x, y := real(p.Z), imag(p.Z)
+		if x < config.XMin || x > config.XMax || y < config.YMin || y > config.YMax {
+			continue
+		}
+		v := value(p)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		c.lo = math.Min(c.lo, v)
+		c.hi = math.Max(c.hi, v)
+	}
+	return c
+}
+
+// ColorSchemes maps --color-by names to Colorer constructors
+var ColorSchemes = map[string]func(points []enumerate.Point, config Config) Colorer{
+	// Leading coefficient: red = 1 (algebraic integers), green = 2, ...
+	"leading": func(points []enumerate.Point, config Config) Colorer {
+		return categoryColorer{class: func(p enumerate.Point) int { return p.LeadingCoeff }, palette: config.categorical()}
+	},
+	// Degree of the polynomial, using the same categorical colors (red = linear, green = quadratic, ...)
+	"degree": func(points []enumerate.Point, config Config) Colorer {
+		return categoryColorer{class: func(p enumerate.Point) int { return p.O }, palette: config.categorical()}
+	},
+	// Root multiplicity: red = simple root, green = double root, ...
+	"multiplicity": func(points []enumerate.Point, config Config) Colorer {
+		return categoryColorer{class: func(p enumerate.Point) int { return p.Mult }, palette: config.categorical()}
+	},
+	// Polynomial height over the full enumerated range, so video frames share one scale
+	"height": func(points []enumerate.Point, config Config) Colorer {
+		return rampColorer{
+			value: func(p enumerate.Point) float64 { return float64(p.H) },
+			lo:    2,
+			hi:    float64(config.MaxHeight),
+			ramp:  config.continuous(),
+			label: func(v float64) string { return strconv.Itoa(int(math.Round(v))) },
+		}
+	},
+	// |Discriminant| on a log scale; repeated roots sit at the bottom of the ramp
+	"discriminant": func(points []enumerate.Point, config Config) Colorer {
+		return newDataRampColorer(func(p enumerate.Point) float64 { return math.Log10(1 + p.Disc) },
+			func(v float64) string { return formatValue(math.Pow(10, v) - 1) }, points, config)
+	},
+	// Mahler measure on a log scale; 1 means a product of cyclotomic polynomials and x
+	"mahler": func(points []enumerate.Point, config Config) Colorer {
+		return newDataRampColorer(func(p enumerate.Point) float64 { return math.Log(p.Mahler) },
+			func(v float64) string { return formatValue(math.Exp(v)) }, points, config)
+	},
+	// Distance of the root from the unit circle
+	"unit-circle": func(points []enumerate.Point, config Config) Colorer {
+		return newDataRampColorer(func(p enumerate.Point) float64 { return math.Abs(cmplx.Abs(p.Z) - 1) },
+			formatValue, points, config)
+	},
+}
+
+// formatValue prints a legend value with three significant digits
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// NewColorer returns the Colorer for the named scheme, falling back to leading coefficient
+func NewColorer(scheme string, points []enumerate.Point, config Config) Colorer {
+	build, ok := ColorSchemes[scheme]
+	if !ok {
+		build = ColorSchemes["leading"]
+	}
+	return build(points, config)
+}
+
+// ColorSchemeNames returns the available --color-by names, sorted
+func ColorSchemeNames() []string {
+	names := make([]string, 0, len(ColorSchemes))
+	for name := range ColorSchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ColorSchemeList returns the available --color-by names for help text
+func ColorSchemeList() string {
+	return strings.Join(ColorSchemeNames(), ", ")
+}
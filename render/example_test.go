@@ -0,0 +1,49 @@
+package render_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/render"
+)
+
+func ExampleImage() {
+	// ±i, the roots of x^2 + 1
+	coeffs := []int{1, 0, 1}
+	points := []enumerate.Point{
+		{Z: 1i, H: 4, O: 2, LeadingCoeff: 1, Mahler: 1, Mult: 1, Coeffs: coeffs},
+		{Z: -1i, H: 4, O: 2, LeadingCoeff: 1, Mahler: 1, Mult: 1, Coeffs: coeffs},
+	}
+	config := render.Config{
+		Width: 200, Height: 200,
+		XMin: -2, YMin: -2, XMax: 2, YMax: 2,
+		MaxHeight: 4,
+		ColorBy:   "leading",
+	}
+	img := render.Image(points, config)
+	fmt.Println(img.RGBAAt(100, 50), img.RGBAAt(100, 100))
+	// Output: {255 0 0 255} {0 0 0 255}
+}
+
+func ExamplePNG() {
+	points, enum := enumerate.Generate(context.Background(), 12, 42)
+	config := render.Config{
+		Width: 1200, Height: 800,
+		XMin: -3, YMin: -2, XMax: 3, YMax: 2,
+		MaxHeight: enum.MaxHeight,
+		ColorBy:   "mahler",
+		Seed:      enum.Seed,
+		Run:       &render.RunInfo{Enumeration: enum, StoppedAt: enum.StoppedAt},
+	}
+	file, err := os.Create("h12.png")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if err := render.PNG(file, points, config); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,276 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// font5x7 is a classic 5x7 bitmap font covering printable ASCII (0x20-0x7E).
+// Each glyph is five columns, left to right; bit 0 of a column is the top row.
+var font5x7 = [95][5]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x00, 0x00, 0x5F, 0x00, 0x00}, // '!'
+	{0x00, 0x07, 0x00, 0x07, 0x00}, // '"'
+	{0x14, 0x7F, 0x14, 0x7F, 0x14}, // '#'
+	{0x24, 0x2A, 0x7F, 0x2A, 0x12}, // '$'
+	{0x23, 0x13, 0x08, 0x64, 0x62}, // '%'
+	{0x36, 0x49, 0x55, 0x22, 0x50}, // '&'
+	{0x00, 0x05, 0x03, 0x00, 0x00}, // '\''
+	{0x00, 0x1C, 0x22, 0x41, 0x00}, // '('
+	{0x00, 0x41, 0x22, 0x1C, 0x00}, // ')'
+	{0x14, 0x08, 0x3E, 0x08, 0x14}, // '*'
+	{0x08, 0x08, 0x3E, 0x08, 0x08}, // '+'
+	{0x00, 0x50, 0x30, 0x00, 0x00}, // ','
+	{0x08, 0x08, 0x08, 0x08, 0x08}, // '-'
+	{0x00, 0x60, 0x60, 0x00, 0x00}, // '.'
+	{0x20, 0x10, 0x08, 0x04, 0x02}, // '/'
+	{0x3E, 0x51, 0x49, 0x45, 0x3E}, // '0'
+	{0x00, 0x42, 0x7F, 0x40, 0x00}, // '1'
+	{0x42, 0x61, 0x51, 0x49, 0x46}, // '2'
+	{0x21, 0x41, 0x45, 0x4B, 0x31}, // '3'
+	{0x18, 0x14, 0x12, 0x7F, 0x10}, // '4'
+	{0x27, 0x45, 0x45, 0x45, 0x39}, // '5'
+	{0x3C, 0x4A, 0x49, 0x49, 0x30}, // '6'
+	{0x01, 0x71, 0x09, 0x05, 0x03}, // '7'
+	{0x36, 0x49, 0x49, 0x49, 0x36}, // '8'
+	{0x06, 0x49, 0x49, 0x29, 0x1E}, // '9'
+	{0x00, 0x36, 0x36, 0x00, 0x00}, // ':'
+	{0x00, 0x56, 0x36, 0x00, 0x00}, // ';'
+	{0x08, 0x14, 0x22, 0x41, 0x00}, // '<'
+	{0x14, 0x14, 0x14, 0x14, 0x14}, // '='
+	{0x00, 0x41, 0x22, 0x14, 0x08}, // '>'
+	{0x02, 0x01, 0x51, 0x09, 0x06}, // '?'
+	{0x32, 0x49, 0x79, 0x41, 0x3E}, // '@'
+	{0x7E, 0x11, 0x11, 0x11, 0x7E}, // 'A'
+	{0x7F, 0x49, 0x49, 0x49, 0x36}, // 'B'
+	{0x3E, 0x41, 0x41, 0x41, 0x22}, // 'C'
+	{0x7F, 0x41, 0x41, 0x22, 0x1C}, // 'D'
+	{0x7F, 0x49, 0x49, 0x49, 0x41}, // 'E'
+	{0x7F, 0x09, 0x09, 0x09, 0x01}, // 'F'
+	{0x3E, 0x41, 0x49, 0x49, 0x7A}, // 'G'
+	{0x7F, 0x08, 0x08, 0x08, 0x7F}, // 'H'
+	{0x00, 0x41, 0x7F, 0x41, 0x00}, // 'I'
+	{0x20, 0x40, 0x41, 0x3F, 0x01}, // 'J'
+	{0x7F, 0x08, 0x14, 0x22, 0x41}, // 'K'
+	{0x7F, 0x40, 0x40, 0x40, 0x40}, // 'L'
+	{0x7F, 0x02, 0x0C, 0x02, 0x7F}, // 'M'
+	{0x7F, 0x04, 0x08, 0x10, 0x7F}, // 'N'
+	{0x3E, 0x41, 0x41, 0x41, 0x3E}, // 'O'
+	{0x7F, 0x09, 0x09, 0x09, 0x06}, // 'P'
+	{0x3E, 0x41, 0x51, 0x21, 0x5E}, // 'Q'
+	{0x7F, 0x09, 0x19, 0x29, 0x46}, // 'R'
+	{0x46, 0x49, 0x49, 0x49, 0x31}, // 'S'
+	{0x01, 0x01, 0x7F, 0x01, 0x01}, // 'T'
+	{0x3F, 0x40, 0x40, 0x40, 0x3F}, // 'U'
+	{0x1F, 0x20, 0x40, 0x20, 0x1F}, // 'V'
+	{0x3F, 0x40, 0x38, 0x40, 0x3F}, // 'W'
+	{0x63, 0x14, 0x08, 0x14, 0x63}, // 'X'
+	{0x07, 0x08, 0x70, 0x08, 0x07}, // 'Y'
+	{0x61, 0x51, 0x49, 0x45, 0x43}, // 'Z'
+	{0x00, 0x7F, 0x41, 0x41, 0x00}, // '['
+	{0x02, 0x04, 0x08, 0x10, 0x20}, // '\\'
+	{0x00, 0x41, 0x41, 0x7F, 0x00}, // ']'
+	{0x04, 0x02, 0x01, 0x02, 0x04}, // '^'
+	{0x40, 0x40, 0x40, 0x40, 0x40}, // '_'
+	{0x00, 0x01, 0x02, 0x04, 0x00}, // '`'
+	{0x20, 0x54, 0x54, 0x54, 0x78}, // 'a'
+	{0x7F, 0x48, 0x44, 0x44, 0x38}, // 'b'
+	{0x38, 0x44, 0x44, 0x44, 0x20}, // 'c'
+	{0x38, 0x44, 0x44, 0x48, 0x7F}, // 'd'
+	{0x38, 0x54, 0x54, 0x54, 0x18}, // 'e'
+	{0x08, 0x7E, 0x09, 0x01, 0x02}, // 'f'
+	{0x0C, 0x52, 0x52, 0x52, 0x3E}, // 'g'
+	{0x7F, 0x08, 0x04, 0x04, 0x78}, // 'h'
+	{0x00, 0x44, 0x7D, 0x40, 0x00}, // 'i'
+	{0x20, 0x40, 0x44, 0x3D, 0x00}, // 'j'
+	{0x7F, 0x10, 0x28, 0x44, 0x00}, // 'k'
+	{0x00, 0x41, 0x7F, 0x40, 0x00}, // 'l'
+	{0x7C, 0x04, 0x18, 0x04, 0x78}, // 'm'
+	{0x7C, 0x08, 0x04, 0x04, 0x78}, // 'n'
+	{0x38, 0x44, 0x44, 0x44, 0x38}, // 'o'
+	{0x7C, 0x14, 0x14, 0x14, 0x08}, // 'p'
+	{0x08, 0x14, 0x14, 0x18, 0x7C}, // 'q'
+	{0x7C, 0x08, 0x04, 0x04, 0x08}, // 'r'
+	{0x48, 0x54, 0x54, 0x54, 0x20}, // 's'
+	{0x04, 0x3F, 0x44, 0x40, 0x20}, // 't'
+	{0x3C, 0x40, 0x40, 0x20, 0x7C}, // 'u'
+	{0x1C, 0x20, 0x40, 0x20, 0x1C}, // 'v'
+	{0x3C, 0x40, 0x30, 0x40, 0x3C}, // 'w'
+	{0x44, 0x28, 0x10, 0x28, 0x44}, // 'x'
+	{0x0C, 0x50, 0x50, 0x50, 0x3C}, // 'y'
+	{0x44, 0x64, 0x54, 0x4C, 0x44}, // 'z'
+	{0x00, 0x08, 0x36, 0x41, 0x00}, // '{'
+	{0x00, 0x00, 0x7F, 0x00, 0x00}, // '|'
+	{0x00, 0x41, 0x36, 0x08, 0x00}, // '}'
+	{0x08, 0x04, 0x08, 0x10, 0x08}, // '~'
+}
+
+// missingGlyph is drawn for characters outside printable ASCII
+var missingGlyph = [5]byte{0x7F, 0x41, 0x41, 0x41, 0x7F}
+
+const (
+	glyphWidth   = 5
+	glyphHeight  = 7
+	glyphAdvance = glyphWidth + 1  // Distance between characters, in font dots
+	lineAdvance  = glyphHeight + 2 // Distance between lines, in font dots
+)
+
+// glyph returns the bitmap for a character
+func glyph(char rune) [5]byte {
+	if char < ' ' || char > '~' {
+		return missingGlyph
+	}
+	return font5x7[char-' ']
+}
+
+// TextStyle controls how text is drawn
+type TextStyle struct {
+	Scale      int        // Screen pixels per font dot; 0 picks one from the image height
+	Color      color.RGBA // Text color
+	Background color.RGBA // Box drawn behind the text; fully transparent draws none
+	Padding    int        // Space between the text and the edge of its background, in font dots
+}
+
+// DefaultTextStyle is white text on a translucent black box
+var DefaultTextStyle = TextStyle{
+	Color:      color.RGBA{255, 255, 255, 255},
+	Background: color.RGBA{0, 0, 0, 180},
+	Padding:    2,
+}
+
+// scaled returns a copy of the style with Scale resolved for an image of the given height
+func (s TextStyle) scaled(imageHeight int) TextStyle {
+	if s.Scale <= 0 {
+		s.Scale = max(1, imageHeight/400)
+	}
+	return s
+}
+
+// measureText returns the pixel size of (possibly multi-line) text, excluding padding
+func measureText(text string, scale int) (width, height int) {
+	lines := strings.Split(text, "\n")
+	longest := 0
+	for _, line := range lines {
+		longest = max(longest, len([]rune(line)))
+	}
+	if longest == 0 {
+		return 0, 0
+	}
+	width = (longest*glyphAdvance - 1) * scale
+	height = ((len(lines)-1)*lineAdvance + glyphHeight) * scale
+	return width, height
+}
+
+// drawChar draws one character with its top-left corner at (x, y), scale pixels per font dot
+func drawChar(img *image.RGBA, char rune, x, y, scale int, textColor color.RGBA) {
+	bitmap := glyph(char)
+	for col, bits := range bitmap {
+		for row := 0; row < glyphHeight; row++ {
+			if bits&(1<<row) != 0 {
+				px, py := x+col*scale, y+row*scale
+				fillRect(img, image.Rect(px, py, px+scale, py+scale), textColor)
+			}
+		}
+	}
+}
+
+// drawString draws text with the top-left corner of its background box at (x, y)
+// and returns the area covered by the box
+func drawString(img *image.RGBA, text string, x, y int, style TextStyle) image.Rectangle {
+	style = style.scaled(img.Bounds().Dy())
+	w, h := measureText(text, style.Scale)
+	pad := style.Padding * style.Scale
+	box := image.Rect(x, y, x+w+2*pad, y+h+2*pad)
+	if style.Background.A > 0 {
+		shadeRect(img, box, style.Background)
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		lineY := y + pad + i*lineAdvance*style.Scale
+		for j, char := range []rune(line) {
+			drawChar(img, char, x+pad+j*glyphAdvance*style.Scale, lineY, style.Scale, style.Color)
+		}
+	}
+	return box
+}
+
+// Anchor places a text box relative to the image edges
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTopRight
+	AnchorBottomLeft
+	AnchorBottomRight
+	AnchorTop
+	AnchorBottom
+)
+
+// anchorNames maps --caption-pos values to anchors
+var anchorNames = map[string]Anchor{
+	"top-left":     AnchorTopLeft,
+	"top-right":    AnchorTopRight,
+	"bottom-left":  AnchorBottomLeft,
+	"bottom-right": AnchorBottomRight,
+	"top":          AnchorTop,
+	"bottom":       AnchorBottom,
+}
+
+// ParseAnchor parses a position name such as "bottom-left"
+func ParseAnchor(name string) (Anchor, error) {
+	if a, ok := anchorNames[name]; ok {
+		return a, nil
+	}
+	return 0, fmt.Errorf("unknown position %q (choose from top-left, top, top-right, bottom-left, bottom, bottom-right)", name)
+}
+
+// DrawLabel draws text in a box at the anchor, in config's text style and as far from the
+// image edges as the overlays, e.g. to number the frames of an animation
+func DrawLabel(img *image.RGBA, text string, anchor Anchor, config Config) {
+	drawAnchoredString(img, text, anchor, overlayMargin, config.Text)
+}
+
+// drawAnchoredString draws text in a box placed at the anchor, margin pixels in from the image edges
+func drawAnchoredString(img *image.RGBA, text string, anchor Anchor, margin int, style TextStyle) image.Rectangle {
+	bounds := img.Bounds()
+	style = style.scaled(bounds.Dy())
+	w, h := measureText(text, style.Scale)
+	pad := style.Padding * style.Scale
+	w, h = w+2*pad, h+2*pad
+
+	x, y := bounds.Min.X+margin, bounds.Min.Y+margin
+	switch anchor {
+	case AnchorTopRight, AnchorBottomRight:
+		x = bounds.Max.X - margin - w
+	case AnchorTop, AnchorBottom:
+		x = bounds.Min.X + (bounds.Dx()-w)/2
+	}
+	switch anchor {
+	case AnchorBottomLeft, AnchorBottomRight, AnchorBottom:
+		y = bounds.Max.Y - margin - h
+	}
+	return drawString(img, text, x, y, style)
+}
+
+// ParseBackground parses a text background: "none", "#rrggbb" (translucent) or "#rrggbbaa"
+func ParseBackground(s string) (color.RGBA, error) {
+	if s == "none" {
+		return color.RGBA{}, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 8 {
+		a, err := strconv.ParseUint(hex[6:], 16, 8)
+		if err != nil {
+			return color.RGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+		}
+		c, err := ParseHexColor(hex[:6])
+		c.A = uint8(a)
+		return c, err
+	}
+	c, err := ParseHexColor(hex)
+	c.A = DefaultTextStyle.Background.A
+	return c, err
+}
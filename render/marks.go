@@ -0,0 +1,195 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+// Marks highlight special numbers over the points, each kind with its own shape and color:
+// a ring for Pisot numbers, a diamond for Salem numbers, a square for the roots outside the
+// unit disk of polynomials of small Mahler measure, and a cross for roots of unity.
+
+// markStyles give the look of each kind of mark
+var markStyles = map[roots.Kind]struct {
+	Label string
+	Color color.RGBA
+}{
+	roots.Pisot:        {"Pisot", color.RGBA{255, 200, 0, 255}},
+	roots.Salem:        {"Salem", color.RGBA{0, 220, 255, 255}},
+	roots.SmallMeasure: {"Small measure", color.RGBA{255, 64, 255, 255}},
+	roots.Cyclotomic:   {"Root of unity", color.RGBA{255, 255, 255, 255}},
+}
+
+var markHalo = color.RGBA{0, 0, 0, 255}
+
+// markSize returns the radius and line width of marks in pixels for the config's image
+func markSize(config Config) (radius, width float64) {
+	radius = math.Min(14, math.Max(5, float64(config.Height)/90))
+	return radius, math.Max(1.5, radius/4)
+}
+
+// markOutline returns the shape of a mark centered on (x, y) as polylines; closed ones end
+// where they start
+func markOutline(kind roots.Kind, x, y, r float64) [][][2]float64 {
+	switch kind {
+	case roots.Pisot:
+		const sides = 24
+		ring := make([][2]float64, sides+1)
+		for i := range ring {
+			a := 2 * math.Pi * float64(i) / sides
+			ring[i] = [2]float64{x + r*math.Cos(a), y + r*math.Sin(a)}
+		}
+		return [][][2]float64{ring}
+	case roots.Salem:
+		return [][][2]float64{{{x, y - r}, {x + r, y}, {x, y + r}, {x - r, y}, {x, y - r}}}
+	case roots.SmallMeasure:
+		s := r * 0.8
+		return [][][2]float64{{{x - s, y - s}, {x + s, y - s}, {x + s, y + s}, {x - s, y + s}, {x - s, y - s}}}
+	default:
+		return [][][2]float64{{{x - r, y}, {x + r, y}}, {{x, y - r}, {x, y + r}}}
+	}
+}
+
+// sortedMarks orders marks for drawing: roots of unity first, since they are the most
+// numerous, then small measures, Salem and Pisot numbers on top
+func sortedMarks(marks []enumerate.Mark) []enumerate.Mark {
+	order := map[roots.Kind]int{roots.Cyclotomic: 0, roots.SmallMeasure: 1, roots.Salem: 2, roots.Pisot: 3}
+	sorted := append([]enumerate.Mark(nil), marks...)
+	sort.SliceStable(sorted, func(i, j int) bool { return order[sorted[i].Kind] < order[sorted[j].Kind] })
+	return sorted
+}
+
+// drawMarks draws config.Marks onto img, which may be a tile of the full image
+func drawMarks(img *image.RGBA, config Config) {
+	radius, width := markSize(config)
+	for _, m := range sortedMarks(config.Marks) {
+		x, y := worldToScreen(real(m.Z), imag(m.Z), config)
+		drawMark(img, m.Kind, x, y, radius, width)
+	}
+}
+
+// drawMark draws one mark in its color over a dark halo
+func drawMark(img *image.RGBA, kind roots.Kind, x, y, radius, width float64) {
+	outline := markOutline(kind, x, y, radius)
+	reach := int(math.Ceil(radius + width))
+	rect := image.Rect(int(x)-reach, int(y)-reach, int(x)+reach+2, int(y)+reach+2).Intersect(img.Bounds())
+	col := markStyles[kind].Color
+	for py := rect.Min.Y; py < rect.Max.Y; py++ {
+		for px := rect.Min.X; px < rect.Max.X; px++ {
+			d := outlineDistance(outline, float64(px)+0.5, float64(py)+0.5)
+			switch {
+			case d <= width/2:
+				img.SetRGBA(px, py, col)
+			case d <= width/2+1:
+				img.SetRGBA(px, py, markHalo)
+			}
+		}
+	}
+}
+
+// outlineDistance is the distance from (x, y) to the nearest segment of the polylines
+func outlineDistance(outline [][][2]float64, x, y float64) float64 {
+	best := math.Inf(1)
+	for _, line := range outline {
+		for i := 1; i < len(line); i++ {
+			a, b := line[i-1], line[i]
+			dx, dy := b[0]-a[0], b[1]-a[1]
+			t := ((x-a[0])*dx + (y-a[1])*dy) / (dx*dx + dy*dy)
+			t = math.Max(0, math.Min(1, t))
+			best = math.Min(best, math.Hypot(x-a[0]-t*dx, y-a[1]-t*dy))
+		}
+	}
+	return best
+}
+
+// markKinds returns the kinds among marks in the order of the key
+func markKinds(marks []enumerate.Mark) []roots.Kind {
+	var kinds []roots.Kind
+	for _, kind := range []roots.Kind{roots.Pisot, roots.Salem, roots.SmallMeasure, roots.Cyclotomic} {
+		for _, m := range marks {
+			if m.Kind == kind {
+				kinds = append(kinds, kind)
+				break
+			}
+		}
+	}
+	return kinds
+}
+
+// drawMarkKey draws a key to the marks in the top-right corner
+func drawMarkKey(img *image.RGBA, marks []enumerate.Mark, margin int, style TextStyle) {
+	kinds := markKinds(marks)
+	if len(kinds) == 0 {
+		return
+	}
+	style = style.scaled(img.Bounds().Dy())
+	const markCols = 3 // Mark width in characters
+	lines := []string{"Highlights"}
+	for _, kind := range kinds {
+		lines = append(lines, strings.Repeat(" ", markCols)+markStyles[kind].Label)
+	}
+	box := drawAnchoredString(img, strings.Join(lines, "\n"), AnchorTopRight, margin, style)
+
+	pad := style.Padding * style.Scale
+	radius := float64(glyphHeight*style.Scale) / 2
+	for i, kind := range kinds {
+		x := float64(box.Min.X+pad) + float64(markCols*glyphAdvance*style.Scale)/2
+		y := float64(box.Min.Y+pad+(i+1)*lineAdvance*style.Scale) + radius
+		drawMark(img, kind, x, y, radius, math.Max(1, radius/3))
+	}
+}
+
+// svgMarks writes config.Marks as SVG paths
+func svgMarks(out io.Writer, config Config) {
+	radius, width := markSize(config)
+	for _, m := range sortedMarks(config.Marks) {
+		x, y := worldToScreen(real(m.Z), imag(m.Z), config)
+		var d strings.Builder
+		for _, line := range markOutline(m.Kind, x, y, radius) {
+			for i, p := range line {
+				cmd := "L"
+				if i == 0 {
+					cmd = "M"
+				}
+				fmt.Fprintf(&d, "%s%.2f %.2f", cmd, p[0], p[1])
+			}
+		}
+		fmt.Fprintf(out, "<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%.2f\"/>\n", d.String(), hexRGB(markHalo), width+2)
+		fmt.Fprintf(out, "<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%.2f\"/>\n", d.String(), hexRGB(markStyles[m.Kind].Color), width)
+	}
+}
+
+// pdfMarks writes config.Marks as stroked PDF paths on a page of the given height
+func pdfMarks(b *bytes.Buffer, config Config, height float64) {
+	radius, width := markSize(config)
+	for _, m := range sortedMarks(config.Marks) {
+		x, y := worldToScreen(real(m.Z), imag(m.Z), config)
+		outline := markOutline(m.Kind, x, y, radius)
+		for _, pass := range []struct {
+			col   color.RGBA
+			width float64
+		}{{markHalo, width + 2}, {markStyles[m.Kind].Color, width}} {
+			fmt.Fprintf(b, "%.3f %.3f %.3f RG %.2f w\n",
+				float64(pass.col.R)/255, float64(pass.col.G)/255, float64(pass.col.B)/255, pass.width)
+			for _, line := range outline {
+				for i, p := range line {
+					op := "l"
+					if i == 0 {
+						op = "m"
+					}
+					fmt.Fprintf(b, "%.2f %.2f %s\n", p[0], height-p[1], op)
+				}
+			}
+			fmt.Fprintf(b, "S\n")
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/internal/buildinfo"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+// JobChunkKey is the iTXt keyword holding the job file that reproduces an image
+const JobChunkKey = "Job"
+
+// SolverChunkKey is the tEXt keyword describing the root finder that found an image's points
+const SolverChunkKey = "Solver"
+
+// Solver describes the root finder of this build as the Solver chunk records it. An image
+// whose Solver differs was solved by another version, and rendering its job again gives
+// other points.
+func Solver() string {
+	return fmt.Sprintf("Newton with deflation, %d iterations, relative tolerance %g, per-polynomial seeds",
+		roots.MaxIterations, roots.Tolerance)
+}
+
+// TextChunk is a keyword/value pair stored as a PNG tEXt chunk
+type TextChunk struct {
+	Key, Value string
+}
+
+// RunInfo records how a render's points were made, so that the output can say how to remake it
+type RunInfo struct {
+	Enumeration enumerate.Enumeration
+	Job         string    // Effective configuration as a TOML job file
+	Start       time.Time // When rendering began
+	StoppedAt   int       // Height an interrupted run stopped during, as in Enumeration; 0 if it finished
+}
+
+// OutputMetadata describes how an output file was produced
+func OutputMetadata(config Config) []TextChunk {
+	palette := "default"
+	if config.Palette != nil {
+		palette = config.Palette.Name
+	}
+	colorBy := config.ColorBy
+	if colorBy == "" {
+		colorBy = "leading"
+	}
+	chunks := []TextChunk{
+		{"Software", buildinfo.Version()},
+		{"Color-By", colorBy},
+		{"Palette", palette},
+	}
+	if run := config.Run; run != nil {
+		enum := run.Enumeration
+		chunks = append(chunks,
+			TextChunk{"Creation Time", run.Start.UTC().Format(time.RFC3339)},
+			TextChunk{"Seed", strconv.FormatInt(config.Seed, 10)},
+			TextChunk{"Enumeration", fmt.Sprintf("%sheights 2-%d, %d polynomials, %d roots",
+				familyPrefix(enum.Family), enum.MaxHeight, enum.PolynomialCount(), enum.Roots)},
+			TextChunk{SolverChunkKey, Solver()},
+			TextChunk{"Viewport", fmt.Sprintf("%g %g %g %g", config.XMin, config.YMin, config.XMax, config.YMax)},
+			TextChunk{"Timing", fmt.Sprintf("enumeration %s, render %s",
+				enum.Duration.Round(time.Millisecond), time.Since(run.Start).Round(time.Millisecond))},
+		)
+		if run.StoppedAt > 0 {
+			chunks = append(chunks, TextChunk{"Partial", enumerate.DescribeStop(run.StoppedAt)})
+		}
+	}
+	return chunks
+}
+
+// familyPrefix names a family other than all polynomials at the start of the Enumeration chunk
+func familyPrefix(f enumerate.Family) string {
+	if f == enumerate.AllPolynomials {
+		return ""
+	}
+	return f.String() + " polynomials, "
+}
+
+// PNGChunk is an ancillary PNG chunk, written between IHDR and the image data
+type PNGChunk struct {
+	Type string
+	Data []byte
+}
+
+// OutputChunks returns the PNG chunks for an output file: the metadata as tEXt, the job
+// as UTF-8 iTXt, plus pHYs when config.DPI is set
+func OutputChunks(config Config) []PNGChunk {
+	var chunks []PNGChunk
+	for _, t := range OutputMetadata(config) {
+		chunks = append(chunks, PNGChunk{"tEXt", []byte(t.Key + "\x00" + t.Value)})
+	}
+	if config.Run != nil && config.Run.Job != "" {
+		// Keyword, no compression, no language tag, no translated keyword
+		chunks = append(chunks, PNGChunk{"iTXt", []byte(JobChunkKey + "\x00\x00\x00\x00\x00" + config.Run.Job)})
+	}
+	if config.DPI > 0 {
+		var phys [9]byte
+		ppm := uint32(config.DPI/0.0254 + 0.5) // pHYs counts pixels per metre
+		binary.BigEndian.PutUint32(phys[0:4], ppm)
+		binary.BigEndian.PutUint32(phys[4:8], ppm)
+		phys[8] = 1 // Unit: metre
+		chunks = append(chunks, PNGChunk{"pHYs", phys[:]})
+	}
+	return chunks
+}
+
+// EncodePNGWithChunks encodes img as PNG with the given chunks placed right after IHDR
+func EncodePNGWithChunks(w io.Writer, img image.Image, chunks []PNGChunk) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	// 8-byte signature, then IHDR: length(4) + type(4) + 13 bytes of data + crc(4)
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return fmt.Errorf("unexpected PNG layout from encoder")
+	}
+
+	if _, err := w.Write(data[:ihdrEnd]); err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := writePNGChunk(w, c.Type, c.Data); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[ihdrEnd:])
+	return err
+}
+
+// writePNGChunk writes one length-prefixed, CRC-terminated PNG chunk
+func writePNGChunk(w io.Writer, chunkType string, payload []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(payload)
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], crc.Sum32())
+
+	for _, part := range [][]byte{header[:], payload, trailer[:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPNGText returns the tEXt and iTXt chunks of a PNG file in file order
+func ReadPNGText(path string) ([]TextChunk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || string(data[:8]) != "\x89PNG\r\n\x1a\n" {
+		return nil, fmt.Errorf("%s is not a PNG file", path)
+	}
+
+	var chunks []TextChunk
+	for pos := 8; pos+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || pos+12+length > len(data) {
+			return nil, fmt.Errorf("%s: truncated %s chunk", path, chunkType)
+		}
+		payload := data[pos+8 : pos+8+length]
+		pos += 12 + length
+
+		switch chunkType {
+		case "tEXt":
+			if key, value, ok := bytes.Cut(payload, []byte{0}); ok {
+				chunks = append(chunks, TextChunk{string(key), string(value)})
+			}
+		case "iTXt":
+			// keyword\0 compressed method language\0 translated\0 text; compressed text is not written here
+			key, rest, ok := bytes.Cut(payload, []byte{0})
+			if !ok || len(rest) < 2 || rest[0] != 0 {
+				continue
+			}
+			parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+			if len(parts) == 3 {
+				chunks = append(chunks, TextChunk{string(key), string(parts[2])})
+			}
+		case "IEND":
+			return chunks, nil
+		}
+	}
+	return chunks, nil
+}
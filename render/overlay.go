@@ -0,0 +1,263 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Overlays selects the annotations drawn on top of a render
+type Overlays struct {
+	Legend     bool // Swatches for the active coloring rule
+	Axes       bool // Tick marks with real/imaginary labels along the edges
+	UnitCircle bool // The circle |z| = 1
+	Caption    bool // Config.Caption, or a summary of the parameters
+}
+
+// OverlayNames lists the values accepted by --overlay
+const OverlayNames = "legend, axes, unit-circle, caption, all"
+
+// ParseOverlays parses a comma-separated --overlay list
+func ParseOverlays(list string) (Overlays, error) {
+	var o Overlays
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "legend":
+			o.Legend = true
+		case "axes":
+			o.Axes = true
+		case "unit-circle":
+			o.UnitCircle = true
+		case "caption":
+			o.Caption = true
+		case "all":
+			o = Overlays{Legend: true, Axes: true, UnitCircle: true, Caption: true}
+		default:
+			return o, fmt.Errorf("unknown overlay %q (choose from %s)", name, OverlayNames)
+		}
+	}
+	return o, nil
+}
+
+const (
+	overlayMargin = 10 // Pixels between overlay boxes and the image edge
+	tickLength    = 6  // Tick length in text-scale units
+)
+
+var overlayLine = color.RGBA{160, 160, 160, 255}
+
+// shadeRect alpha-blends a color over a rectangle, clipped to the image
+func shadeRect(img *image.RGBA, rect image.Rectangle, shade color.RGBA) {
+	rect = rect.Intersect(img.Bounds())
+	alpha := float64(shade.A) / 255.0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			existing := img.RGBAAt(x, y)
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(float64(shade.R)*alpha + float64(existing.R)*(1-alpha)),
+				G: uint8(float64(shade.G)*alpha + float64(existing.G)*(1-alpha)),
+				B: uint8(float64(shade.B)*alpha + float64(existing.B)*(1-alpha)),
+				A: 255,
+			})
+		}
+	}
+}
+
+// fillRect paints a solid rectangle, clipped to the image
+func fillRect(img *image.RGBA, rect image.Rectangle, col color.RGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+// worldToScreen maps a point of the complex plane to (fractional) pixel coordinates
+func worldToScreen(x, y float64, config Config) (float64, float64) {
+	sx := (x - config.XMin) / (config.XMax - config.XMin) * float64(config.Width)
+	sy := (config.YMax - y) / (config.YMax - config.YMin) * float64(config.Height) // Flip Y
+	return sx, sy
+}
+
+// drawOverlays draws the annotations selected in config.Overlays
+func drawOverlays(img *image.RGBA, colorer Colorer, config Config) {
+	if config.Overlays.UnitCircle {
+		DrawUnitCircle(img, config)
+	}
+	drawMarks(img, config)
+	if config.Overlays.Axes {
+		drawAxes(img, config)
+	}
+	// Keep boxes clear of the axis labels
+	margin := overlayMargin
+	if config.Overlays.Axes {
+		margin += axesInset(config.Text.scaled(img.Bounds().Dy()).Scale)
+	}
+	if config.Overlays.Legend {
+		if legender, ok := colorer.(Legender); ok {
+			drawLegend(img, colorSchemeTitle(config.ColorBy), legender.Legend(), margin, config.Text)
+		}
+		drawMarkKey(img, config.Marks, margin, config.Text)
+	}
+	if config.Overlays.Caption {
+		caption := config.Caption
+		if caption == "" {
+			caption = defaultCaption(config)
+		}
+		drawAnchoredString(img, caption, config.CaptionAnchor, margin, config.Text)
+	}
+}
+
+// axesInset is how far the axis ticks and labels reach in from the image edge
+func axesInset(scale int) int {
+	const labelChars = 6
+	return (tickLength + (labelChars+1)*glyphAdvance) * scale
+}
+
+// colorSchemeTitle returns the legend heading for a --color-by rule
+func colorSchemeTitle(scheme string) string {
+	switch scheme {
+	case "", "leading":
+		return "Leading coeff"
+	case "unit-circle":
+		return "||z| - 1|"
+	default:
+		return strings.ToUpper(scheme[:1]) + scheme[1:]
+	}
+}
+
+// defaultCaption summarizes the enumeration and view parameters
+func defaultCaption(config Config) string {
+	palette := "default"
+	if config.Palette != nil {
+		palette = config.Palette.Name
+	}
+	colorBy := config.ColorBy
+	if colorBy == "" {
+		colorBy = "leading"
+	}
+	return fmt.Sprintf("Heights 2-%d, degree <= %d, color by %s, palette %s\nView %s to %s",
+		config.MaxHeight, config.MaxHeight-1, colorBy, palette,
+		formatComplex(config.XMin, config.YMin), formatComplex(config.XMax, config.YMax))
+}
+
+// formatComplex prints x + yi compactly
+func formatComplex(x, y float64) string {
+	sign := "+"
+	if y < 0 {
+		sign, y = "-", -y
+	}
+	return strconv.FormatFloat(x, 'g', 6, 64) + sign + strconv.FormatFloat(y, 'g', 6, 64) + "i"
+}
+
+// DrawUnitCircle traces |z| = 1 with a step of at most half a pixel
+func DrawUnitCircle(img *image.RGBA, config Config) {
+	cx, cy := worldToScreen(0, 0, config)
+	rx, _ := worldToScreen(1, 0, config)
+	_, ry := worldToScreen(0, 1, config)
+	radius := math.Max(math.Abs(rx-cx), math.Abs(ry-cy))
+	steps := int(4*math.Pi*radius) + 1
+
+	bounds := img.Bounds()
+	for s := 0; s < steps; s++ {
+		theta := 2 * math.Pi * float64(s) / float64(steps)
+		sx, sy := worldToScreen(math.Cos(theta), math.Sin(theta), config)
+		px, py := int(sx), int(sy)
+		if image.Pt(px, py).In(bounds) {
+			img.SetRGBA(px, py, overlayLine)
+		}
+	}
+}
+
+// niceStep returns a tick spacing of 1, 2 or 5 times a power of ten giving roughly target ticks over span
+func niceStep(span float64, target int) float64 {
+	raw := span / float64(target)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*mag >= raw {
+			return m * mag
+		}
+	}
+	return 10 * mag
+}
+
+// formatTick prints a tick value with just enough decimals for the step
+func formatTick(v, step float64) string {
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step)))
+	}
+	if math.Abs(v) < step/2 {
+		v = 0 // Avoid "-0"
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// drawAxes draws tick marks and labels for the real axis along the bottom edge
+// and the imaginary axis along the left edge
+func drawAxes(img *image.RGBA, config Config) {
+	bounds := img.Bounds()
+	style := config.Text.scaled(bounds.Dy())
+	style.Background = color.RGBA{}
+	style.Padding = 0
+	tick := tickLength * style.Scale
+	textH := glyphHeight * style.Scale
+
+	// Real axis; labels that would run off the sides are dropped
+	step := niceStep(config.XMax-config.XMin, 8)
+	for v := math.Ceil(config.XMin/step) * step; v <= config.XMax; v += step {
+		sx, _ := worldToScreen(v, 0, config)
+		x := int(sx)
+		fillRect(img, image.Rect(x, bounds.Max.Y-tick, x+style.Scale, bounds.Max.Y), style.Color)
+		label := formatTick(v, step)
+		w, _ := measureText(label, style.Scale)
+		if x-w/2 >= bounds.Min.X && x+w/2 < bounds.Max.X {
+			drawString(img, label, x-w/2, bounds.Max.Y-tick-textH-2*style.Scale, style)
+		}
+	}
+
+	// Imaginary axis; labels are kept clear of the real axis labels and the top edge
+	labelBand := tick + textH + 2*style.Scale
+	step = niceStep(config.YMax-config.YMin, 8)
+	for v := math.Ceil(config.YMin/step) * step; v <= config.YMax; v += step {
+		_, sy := worldToScreen(0, v, config)
+		y := int(sy)
+		fillRect(img, image.Rect(0, y, tick, y+style.Scale), style.Color)
+		label := formatTick(v, step)
+		if math.Abs(v) >= step/2 {
+			label += "i"
+		}
+		if y-textH/2 >= bounds.Min.Y && y+textH/2 < bounds.Max.Y-labelBand {
+			drawString(img, label, tick+glyphAdvance*style.Scale, y-textH/2, style)
+		}
+	}
+}
+
+// drawLegend draws a title and color swatches with their labels in the top-left corner
+func drawLegend(img *image.RGBA, title string, entries []LegendEntry, margin int, style TextStyle) {
+	if len(entries) == 0 {
+		return
+	}
+
+	// Lay the legend out as text, leaving room on the left of each entry for its swatch
+	style = style.scaled(img.Bounds().Dy())
+	const swatchCols = 3 // Swatch width in characters
+	lines := []string{title}
+	for _, e := range entries {
+		lines = append(lines, strings.Repeat(" ", swatchCols)+e.Label)
+	}
+	box := drawString(img, strings.Join(lines, "\n"), margin, margin, style)
+
+	pad := style.Padding * style.Scale
+	swatch := glyphHeight * style.Scale
+	for i, e := range entries {
+		x := box.Min.X + pad
+		y := box.Min.Y + pad + (i+1)*lineAdvance*style.Scale
+		fillRect(img, image.Rect(x, y, x+(swatchCols*glyphAdvance-2)*style.Scale, y+swatch), e.Color)
+	}
+}
@@ -0,0 +1,197 @@
+package render
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Palette is a named list of colors. Categorical rules (leading coefficient, degree,
+// multiplicity) pick entries by index; continuous rules interpolate through them.
+type Palette struct {
+	Name       string
+	Colors     []color.RGBA
+	Continuous bool // Colors are stops of a smooth colormap rather than distinct categories
+}
+
+// categoryCount is how many classes a continuous palette is split into for categorical rules
+const categoryCount = 10
+
+// category returns the color for class n (1-based); classes past the end share the last color
+func (p *Palette) category(n int) color.RGBA {
+	if p.Continuous {
+		return colorRamp(p.Colors).at(float64(n-1) / float64(categoryCount-1))
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > len(p.Colors) {
+		n = len(p.Colors)
+	}
+	return p.Colors[n-1]
+}
+
+// ramp returns the palette as a color ramp for continuous rules
+func (p *Palette) ramp() colorRamp {
+	return colorRamp(p.Colors)
+}
+
+// hexColors parses "#rrggbb" strings into colors, panicking on malformed built-in tables
+func hexColors(hexes ...string) []color.RGBA {
+	colors := make([]color.RGBA, len(hexes))
+	for i, h := range hexes {
+		c, err := ParseHexColor(h)
+		if err != nil {
+			panic(err)
+		}
+		colors[i] = c
+	}
+	return colors
+}
+
+// ParseHexColor parses "#rrggbb" or "rrggbb"
+func ParseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: want #rrggbb", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// builtinPalettes are available by name through --palette
+var builtinPalettes = map[string]*Palette{
+	// The original ten colors plus white for higher classes
+	"classic": {Name: "classic", Colors: classicColors},
+	// Blue -> cyan -> green -> yellow -> red
+	"rainbow": {Name: "rainbow", Colors: defaultRamp, Continuous: true},
+	// Perceptually uniform colormaps from matplotlib, sampled at 9 stops
+	"viridis": {Name: "viridis", Continuous: true, Colors: hexColors(
+		"#440154", "#472d7b", "#3b528b", "#2c728e", "#21918c", "#28ae80", "#5ec962", "#addc30", "#fde725")},
+	"magma": {Name: "magma", Continuous: true, Colors: hexColors(
+		"#000004", "#1c1044", "#4f127b", "#812581", "#b5367a", "#e55964", "#fb8761", "#fec287", "#fcfdbf")},
+	"cividis": {Name: "cividis", Continuous: true, Colors: hexColors(
+		"#00224e", "#123570", "#3b496c", "#575d6d", "#707173", "#8a8678", "#a59c74", "#c3b369", "#fee838")},
+	// Okabe-Ito colour-blind-safe categorical set, with white replacing black for the black background
+	"okabe-ito": {Name: "okabe-ito", Colors: hexColors(
+		"#e69f00", "#56b4e9", "#009e73", "#f0e442", "#0072b2", "#d55e00", "#cc79a7", "#ffffff")},
+}
+
+// PaletteList returns the built-in palette names for help text
+func PaletteList() string {
+	names := make([]string, 0, len(builtinPalettes))
+	for name := range builtinPalettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// LoadPalette resolves a --palette value: a built-in name, or a path to a .json or .gpl file
+func LoadPalette(spec string) (*Palette, error) {
+	if p, ok := builtinPalettes[spec]; ok {
+		return p, nil
+	}
+
+	var p *Palette
+	var err error
+	switch strings.ToLower(filepath.Ext(spec)) {
+	case ".json":
+		p, err = loadJSONPalette(spec)
+	case ".gpl":
+		p, err = loadGPLPalette(spec)
+	default:
+		return nil, fmt.Errorf("unknown palette %q (choose from %s, or a .json/.gpl file)", spec, PaletteList())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Colors) == 0 {
+		return nil, fmt.Errorf("palette %s has no colors", spec)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(spec), filepath.Ext(spec))
+	}
+	return p, nil
+}
+
+// loadJSONPalette reads {"name": "...", "continuous": false, "colors": ["#rrggbb", ...]}
+func loadJSONPalette(path string) (*Palette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read palette: %v", err)
+	}
+
+	var raw struct {
+		Name       string   `json:"name"`
+		Continuous bool     `json:"continuous"`
+		Colors     []string `json:"colors"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse palette %s: %v", path, err)
+	}
+
+	p := &Palette{Name: raw.Name, Continuous: raw.Continuous}
+	for _, h := range raw.Colors {
+		c, err := ParseHexColor(h)
+		if err != nil {
+			return nil, fmt.Errorf("palette %s: %v", path, err)
+		}
+		p.Colors = append(p.Colors, c)
+	}
+	return p, nil
+}
+
+// loadGPLPalette reads a GIMP palette: a "GIMP Palette" header, optional Name:/Columns: lines,
+// # comments, then one "R G B [label]" line per color
+func loadGPLPalette(path string) (*Palette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read palette: %v", err)
+	}
+	defer file.Close()
+
+	p := &Palette{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case lineNum == 1:
+			if line != "GIMP Palette" {
+				return nil, fmt.Errorf("palette %s: missing \"GIMP Palette\" header", path)
+			}
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Columns:"):
+		case strings.HasPrefix(line, "Name:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(line, "Name:"))
+		default:
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("palette %s line %d: want \"R G B\"", path, lineNum)
+			}
+			var rgb [3]uint8
+			for i := range rgb {
+				v, err := strconv.ParseUint(fields[i], 10, 8)
+				if err != nil {
+					return nil, fmt.Errorf("palette %s line %d: %v", path, lineNum, err)
+				}
+				rgb[i] = uint8(v)
+			}
+			p.Colors = append(p.Colors, color.RGBA{rgb[0], rgb[1], rgb[2], 255})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read palette: %v", err)
+	}
+	return p, nil
+}
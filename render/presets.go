@@ -0,0 +1,126 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Preset is a named view of the complex plane
+type Preset struct {
+	Description string  `json:"description"`
+	Center      string  `json:"center"` // Complex number, e.g. "0.5+0.866i"
+	Span        float64 `json:"span"`   // Width of the view; the height follows the image shape
+}
+
+// builtinPresets are always available; user presets with the same name replace them
+var builtinPresets = map[string]Preset{
+	"overview": {
+		Description: "Four units wide about the origin, where nearly all the roots lie",
+		Center:      "0",
+		Span:        4,
+	},
+	"unit-circle": {
+		Description: "The whole unit circle in a 3:2 image, where the roots of reciprocal polynomials gather",
+		Center:      "0",
+		Span:        3.6,
+	},
+	"unit-circle-1": {
+		Description: "The unit circle where it crosses the real axis at 1",
+		Center:      "1",
+		Span:        0.5,
+	},
+	"near-i": {
+		Description: "The neighbourhood of i, ringed by roots that keep their distance",
+		Center:      "1i",
+		Span:        0.5,
+	},
+	"golden-ratio": {
+		Description: "The real axis around the golden ratio (1+sqrt 5)/2, a root of x^2 - x - 1",
+		Center:      "1.6180339887",
+		Span:        0.2,
+	},
+	"littlewood-hole": {
+		Description: "The gap around the sixth root of unity e^(i pi/3) on the unit circle",
+		Center:      "0.5+0.8660254038i",
+		Span:        0.3,
+	},
+	"eisenstein": {
+		Description: "The cube root of unity e^(2i pi/3), a root of x^2 + x + 1",
+		Center:      "-0.5+0.8660254038i",
+		Span:        0.3,
+	},
+}
+
+// UserPresetsFile is where user presets are read from when it exists
+func UserPresetsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "algebraic_vis", "presets.json")
+}
+
+// LoadPresets returns the built-in presets, extended by the user presets file and then by
+// extraFile if it is not empty. Both files are JSON objects mapping names to presets.
+func LoadPresets(extraFile string) (map[string]Preset, error) {
+	presets := make(map[string]Preset, len(builtinPresets))
+	for name, p := range builtinPresets {
+		presets[name] = p
+	}
+
+	if path := UserPresetsFile(); path != "" {
+		if err := readPresets(path, presets); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if extraFile != "" {
+		if err := readPresets(extraFile, presets); err != nil {
+			return nil, err
+		}
+	}
+	return presets, nil
+}
+
+// readPresets adds the presets in a JSON file to presets
+func readPresets(path string, presets map[string]Preset) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file map[string]Preset
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse presets %s: %v", path, err)
+	}
+	for name, p := range file {
+		if _, err := parseComplex(p.Center); err != nil {
+			return fmt.Errorf("preset %q in %s: %v", name, path, err)
+		}
+		if p.Span <= 0 {
+			return fmt.Errorf("preset %q in %s: span must be positive", name, path)
+		}
+		presets[name] = p
+	}
+	return nil
+}
+
+// parseComplex parses "re+imi" forms such as "1.5", "-0.5+0.866i", "2i" and "1-i"
+func parseComplex(s string) (complex128, error) {
+	s = strings.TrimSpace(s)
+	// strconv wants an explicit coefficient on i
+	if strings.HasSuffix(s, "i") {
+		if head := s[:len(s)-1]; head == "" || strings.HasSuffix(head, "+") || strings.HasSuffix(head, "-") {
+			s = head + "1i"
+		}
+	}
+	z, err := strconv.ParseComplex(s, 128)
+	if err != nil {
+		return 0, fmt.Errorf("invalid complex number %q: want re+imi", s)
+	}
+	return z, nil
+}
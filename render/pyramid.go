@@ -0,0 +1,251 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/internal/logging"
+)
+
+const (
+	PyramidTileSize = 256  // Edge of an XYZ / DZI tile in pixels
+	PyramidMaxZoom  = 14   // 4^14 tiles is already far more than a disk wants
+	pyramidRefWidth = 1200 // Canvas width the blob-radius rules were tuned for
+)
+
+// pyramidInfo is written as pyramid.json so viewers can map tiles back to the complex plane
+type pyramidInfo struct {
+	TileSize   int
+	MinZoom    int
+	MaxZoom    int
+	XMin, YMin float64
+	XMax, YMax float64
+	Tiles      string // XYZ path template
+	DZI        string // Deep Zoom descriptor
+	MaxHeight  int
+	ColorBy    string
+	Seed       int64 // Reproduces the pyramid's points
+}
+
+// PyramidLevel returns the canvas for zoom level z: 2^z x 2^z tiles over the whole viewport,
+// with blobs scaled to the level's resolution
+func PyramidLevel(config Config, z int) Config {
+	config.Width = PyramidTileSize << z
+	config.Height = config.Width
+	config.BlobScale = float64(config.Width) / pyramidRefWidth
+	return config
+}
+
+// Pyramid writes XYZ tiles as dir/z/x/y.png for zooms 0..maxZoom, a Deep Zoom
+// descriptor pyramid.dzi with its pyramid_files tree, and pyramid.json. When ctx is
+// cancelled the level in progress is dropped and the pyramid ends at the level before.
+func Pyramid(ctx context.Context, points []enumerate.Point, config Config, maxZoom int) error {
+	log := logging.Or(config.Logger)
+	dir := config.OutputFile
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create pyramid directory: %v", err)
+	}
+
+	// One colorer for every level, so a root keeps its color as you zoom
+	colorer := NewColorer(config.ColorBy, points, config)
+	chunks := OutputChunks(config)
+
+	// Tiles no blob reaches are all black; encode that once
+	var blank bytes.Buffer
+	if err := EncodePNGWithChunks(&blank, blackTile(), chunks); err != nil {
+		return fmt.Errorf("failed to encode tile: %v", err)
+	}
+
+	var overview *image.RGBA // The single zoom-0 tile, reused for the small DZI levels
+	for z := 0; z <= maxZoom; z++ {
+		level := PyramidLevel(config, z)
+		g := newTileGrid(level, PyramidTileSize)
+		for x := 0; x < g.Cols; x++ {
+			if err := os.MkdirAll(filepath.Join(dir, fmt.Sprint(z), fmt.Sprint(x)), 0755); err != nil {
+				return fmt.Errorf("failed to create pyramid directory: %v", err)
+			}
+		}
+
+		buckets := g.bucketPoints(points, level)
+		var tiles []int
+		for t, bucket := range buckets {
+			if len(bucket) > 0 || config.Overlays.UnitCircle {
+				tiles = append(tiles, t)
+				continue
+			}
+			if err := os.WriteFile(xyzTilePath(dir, z, t%g.Cols, t/g.Cols), blank.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write tile: %v", err)
+			}
+		}
+		log.Info("Rendering zoom level", "zoom", z, "max_zoom", maxZoom, "tiles", g.Cols*g.Rows, "with_points", len(tiles))
+
+		levelCtx := ctx
+		if z == 0 {
+			levelCtx = context.WithoutCancel(ctx) // A pyramid needs at least its single zoom-0 tile
+		}
+		var written atomic.Int64
+		err := renderTiles(levelCtx, g, buckets, tiles, colorer, level, func(t int, img *image.RGBA) error {
+			written.Add(1)
+			if z == 0 {
+				overview = img // Zoom 0 is a single tile
+			}
+			file, err := os.Create(xyzTilePath(dir, z, t%g.Cols, t/g.Cols))
+			if err != nil {
+				return fmt.Errorf("failed to create tile: %v", err)
+			}
+			defer file.Close()
+			return EncodePNGWithChunks(file, img, chunks)
+		})
+		if err != nil {
+			return err
+		}
+		if int(written.Load()) < len(tiles) {
+			if err := os.RemoveAll(filepath.Join(dir, fmt.Sprint(z))); err != nil {
+				return fmt.Errorf("failed to remove partial zoom level: %v", err)
+			}
+			maxZoom = z - 1
+			log.Warn(fmt.Sprintf("Interrupted: keeping zoom levels 0-%d", maxZoom))
+			break
+		}
+	}
+	if overview == nil {
+		overview = blackTile()
+	}
+
+	if err := writeDZI(dir, overview, maxZoom, chunks); err != nil {
+		return err
+	}
+
+	info, err := json.MarshalIndent(pyramidInfo{
+		TileSize: PyramidTileSize, MinZoom: 0, MaxZoom: maxZoom,
+		XMin: config.XMin, YMin: config.YMin, XMax: config.XMax, YMax: config.YMax,
+		Tiles: "{z}/{x}/{y}.png", DZI: "pyramid.dzi",
+		MaxHeight: config.MaxHeight, ColorBy: config.ColorBy, Seed: config.Seed,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pyramid.json"), append(info, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write pyramid.json: %v", err)
+	}
+
+	log.Info("Saved pyramid", "path", dir)
+	return nil
+}
+
+// xyzTilePath returns dir/z/x/y.png
+func xyzTilePath(dir string, z, x, y int) string {
+	return filepath.Join(dir, fmt.Sprint(z), fmt.Sprint(x), fmt.Sprintf("%d.png", y))
+}
+
+// blackTile returns an empty pyramid tile
+func blackTile() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, PyramidTileSize, PyramidTileSize))
+	fillRect(img, img.Bounds(), color.RGBA{0, 0, 0, 255})
+	return img
+}
+
+// writeDZI writes pyramid.dzi and its pyramid_files/<level>/<col>_<row>.png tiles.
+// Deep Zoom level 8+z holds the same tiles as XYZ zoom z, so those are hard-linked (or copied);
+// levels 0-7, smaller than one tile, are halvings of the zoom-0 tile.
+func writeDZI(dir string, overview *image.RGBA, maxZoom int, chunks []PNGChunk) error {
+	const baseLevel = 8 // log2(PyramidTileSize)
+	files := filepath.Join(dir, "pyramid_files")
+
+	img := overview
+	for level := baseLevel - 1; level >= 0; level-- {
+		img = halveImage(img)
+		levelDir := filepath.Join(files, fmt.Sprint(level))
+		if err := os.MkdirAll(levelDir, 0755); err != nil {
+			return fmt.Errorf("failed to create DZI directory: %v", err)
+		}
+		file, err := os.Create(filepath.Join(levelDir, "0_0.png"))
+		if err != nil {
+			return fmt.Errorf("failed to create tile: %v", err)
+		}
+		err = EncodePNGWithChunks(file, img, chunks)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to encode tile: %v", err)
+		}
+	}
+
+	for z := 0; z <= maxZoom; z++ {
+		levelDir := filepath.Join(files, fmt.Sprint(baseLevel+z))
+		if err := os.MkdirAll(levelDir, 0755); err != nil {
+			return fmt.Errorf("failed to create DZI directory: %v", err)
+		}
+		n := 1 << z
+		for x := 0; x < n; x++ {
+			for y := 0; y < n; y++ {
+				if err := linkOrCopy(xyzTilePath(dir, z, x, y), filepath.Join(levelDir, fmt.Sprintf("%d_%d.png", x, y))); err != nil {
+					return fmt.Errorf("failed to write DZI tile: %v", err)
+				}
+			}
+		}
+	}
+
+	size := PyramidTileSize << maxZoom
+	dzi := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" TileSize="%d" Overlap="0" Format="png">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`, PyramidTileSize, size, size)
+	if err := os.WriteFile(filepath.Join(dir, "pyramid.dzi"), []byte(dzi), 0644); err != nil {
+		return fmt.Errorf("failed to write pyramid.dzi: %v", err)
+	}
+	return nil
+}
+
+// halveImage box-filters img down to half its size in each direction
+func halveImage(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, max(1, b.Dx()/2), max(1, b.Dy()/2)))
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			var sum [4]int
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					c := img.RGBAAt(b.Min.X+min(2*x+dx, b.Dx()-1), b.Min.Y+min(2*y+dy, b.Dy()-1))
+					sum[0] += int(c.R)
+					sum[1] += int(c.G)
+					sum[2] += int(c.B)
+					sum[3] += int(c.A)
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{uint8(sum[0] / 4), uint8(sum[1] / 4), uint8(sum[2] / 4), uint8(sum[3] / 4)})
+		}
+	}
+	return out
+}
+
+// linkOrCopy hard-links src to dst, copying where links are not supported
+func linkOrCopy(src, dst string) error {
+	os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
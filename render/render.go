@@ -0,0 +1,211 @@
+// Package render draws Points from package enumerate as images: PNG, tiled and streamed
+// PNG, deep-zoom pyramids, SVG and PDF, with the coloring, palettes, viewports and
+// overlays described by a Config.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/internal/logging"
+)
+
+// Config holds rendering parameters
+type Config struct {
+	Width, Height   int
+	XMin, YMin      float64
+	XMax, YMax      float64
+	MaxHeight       int
+	OutputFile      string
+	VideoMode       bool
+	FrameRate       int
+	ColorBy         string
+	Palette         *Palette // nil uses classic colors for categorical rules and rainbow for continuous ones
+	Overlays        Overlays
+	Text            TextStyle // Style for captions, legends and labels
+	Caption         string    // Caption text; empty uses a summary of the parameters
+	CaptionAnchor   Anchor
+	VectorMaxPoints int              // SVG/PDF output switches to density contours above this many points; 0 never does
+	BlobScale       float64          // Multiplies blob radii for canvases finer than the default; 0 means 1
+	DPI             float64          // Physical resolution recorded in PNG, SVG and PDF output; 0 leaves it unset
+	Seed            int64            // Seed the roots were found with
+	Run             *RunInfo         // How the points were made, for the output metadata; nil records only the settings
+	Marks           []enumerate.Mark // Special numbers to highlight over the points
+	Logger          *slog.Logger     // Where progress is logged; nil logs nothing
+}
+
+// drawBlob draws a gaussian blob at the specified location with proper falloff
+func drawBlob(img *image.RGBA, x, y int, radius float64, col color.RGBA) {
+	bounds := img.Bounds()
+	r := int(radius + 5) // Extend more for larger blobs
+
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			px, py := x+dx, y+dy
+			if px < bounds.Min.X || px >= bounds.Max.X || py < bounds.Min.Y || py >= bounds.Max.Y {
+				continue
+			}
+
+			dist := math.Sqrt(float64(dx*dx + dy*dy))
+			// Gaussian falloff with wider spread for dramatic glow effect
+			sigma := radius / 2.5 // Wider gaussian
+			intensity := math.Exp(-dist * dist / (2 * sigma * sigma))
+
+			if intensity > 0.005 { // Lower threshold for more glow
+				// Get existing pixel
+				existing := img.RGBAAt(px, py)
+
+				// Add new color with intensity (additive blending)
+				newR := float64(existing.R) + float64(col.R)*intensity
+				newG := float64(existing.G) + float64(col.G)*intensity
+				newB := float64(existing.B) + float64(col.B)*intensity
+
+				// Clamp to 255
+				if newR > 255 {
+					newR = 255
+				}
+				if newG > 255 {
+					newG = 255
+				}
+				if newB > 255 {
+					newB = 255
+				}
+
+				img.SetRGBA(px, py, color.RGBA{
+					R: uint8(newR),
+					G: uint8(newG),
+					B: uint8(newB),
+					A: 255,
+				})
+			}
+		}
+	}
+}
+
+// classicColors are the original leading coefficient colors, white for higher coefficients
+var classicColors = []color.RGBA{
+	{255, 0, 0, 255},     // Red (algebraic integers)
+	{0, 255, 0, 255},     // Green
+	{0, 0, 255, 255},     // Blue
+	{255, 255, 0, 255},   // Yellow
+	{255, 0, 255, 255},   // Magenta
+	{0, 255, 255, 255},   // Cyan
+	{255, 128, 0, 255},   // Orange
+	{128, 255, 0, 255},   // Lime
+	{255, 0, 128, 255},   // Hot pink
+	{128, 0, 255, 255},   // Purple
+	{255, 255, 255, 255}, // White for higher coefficients
+}
+
+// blobRadius returns the blob size in pixels for a polynomial of height h in the config's viewport
+func blobRadius(h int, config Config) float64 {
+	scale := config.BlobScale
+	if scale == 0 {
+		scale = 1
+	}
+
+	// Calculate blob size (lower height = larger dots) - much larger like Wikipedia image
+	k1 := 25.0 * (4.0 / (config.XMax - config.XMin)) * scale // Much larger base size
+	k2 := 0.5
+	radius := k1 * math.Pow(k2, float64(h-3))
+
+	// Larger minimum, shrunk for coarse canvases so overviews are not swamped
+	minRadius := math.Max(1, 3.0*math.Min(scale, 1))
+	if radius < minRadius {
+		radius = minRadius
+	}
+	if radius > 80 {
+		radius = 80
+	} // Much larger maximum
+	return radius
+}
+
+// Image creates an image in memory and returns it
+func Image(points []enumerate.Point, config Config) *image.RGBA {
+	log := logging.Or(config.Logger)
+	img := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
+	colorer := NewColorer(config.ColorBy, points, config)
+
+	log.Info("Rendering", "points", len(points), "width", config.Width, "height", config.Height)
+	DrawPoints(img, points, colorer, config)
+	drawOverlays(img, colorer, config)
+
+	return img
+}
+
+// DrawPoints fills img with black and draws the points that fall inside the viewport.
+// img may cover just part of the config.Width x config.Height canvas, as when rendering tiles.
+func DrawPoints(img *image.RGBA, points []enumerate.Point, colorer Colorer, config Config) {
+	// Fill background with black
+	bounds := img.Bounds()
+	black := color.RGBA{0, 0, 0, 255}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.SetRGBA(x, y, black)
+		}
+	}
+
+	xRange := config.XMax - config.XMin
+	yRange := config.YMax - config.YMin
+
+	for _, point := range points {
+		// Skip points outside viewport
+		x, y := real(point.Z), imag(point.Z)
+		if x < config.XMin || x > config.XMax || y < config.YMin || y > config.YMax {
+			continue
+		}
+
+		// Transform to screen coordinates
+		screenX := int((x - config.XMin) / xRange * float64(config.Width))
+		screenY := int((config.YMax - y) / yRange * float64(config.Height)) // Flip Y
+
+		radius := blobRadius(point.H, config)
+
+		// Color based on the selected scheme (leading coefficient by default)
+		color := colorer.Color(point)
+		drawBlob(img, screenX, screenY, radius, color)
+	}
+}
+
+// WriteFile renders to a file, choosing SVG, PDF or PNG from the output extension
+func WriteFile(points []enumerate.Point, config Config) error {
+	log := logging.Or(config.Logger)
+	file, err := os.Create(config.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(config.OutputFile)) {
+	case ".svg":
+		err = SVG(file, points, config)
+	case ".pdf":
+		err = PDF(file, points, config)
+	default:
+		err = PNG(file, points, config)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("Saved image", "path", config.OutputFile)
+	return nil
+}
+
+// PNG rasterizes with Image and encodes the result as PNG
+func PNG(w io.Writer, points []enumerate.Point, config Config) error {
+	img := Image(points, config)
+
+	if err := EncodePNGWithChunks(w, img, OutputChunks(config)); err != nil {
+		return fmt.Errorf("failed to encode PNG: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package render
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+func TestDrawBlobSymmetric(t *testing.T) {
+	const size, c = 101, 50
+	for _, radius := range []float64{0.5, 1, 3, 7.3, 25, 40} {
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		drawBlob(img, c, c, radius, color.RGBA{200, 120, 40, 255})
+		if got := img.RGBAAt(c, c); got != (color.RGBA{200, 120, 40, 255}) {
+			t.Errorf("radius %g: center %v, want the full color", radius, got)
+		}
+		for dy := 0; dy <= c; dy++ {
+			for dx := 0; dx <= c; dx++ {
+				want := img.RGBAAt(c+dx, c+dy)
+				for _, p := range [][2]int{{-dx, dy}, {dx, -dy}, {-dx, -dy}, {dy, dx}, {-dy, -dx}} {
+					if got := img.RGBAAt(c+p[0], c+p[1]); got != want {
+						t.Fatalf("radius %g: pixel at offset %v is %v, at (%d,%d) %v", radius, p, got, dx, dy, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDrawBlobClipped(t *testing.T) {
+	// A blob over the edge matches the same part of one drawn whole
+	whole := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	drawBlob(whole, 30, 30, 10, color.RGBA{255, 255, 255, 255})
+	clipped := image.NewRGBA(image.Rect(0, 0, 35, 40))
+	drawBlob(clipped, 30, 30, 10, color.RGBA{255, 255, 255, 255})
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 35; x++ {
+			if clipped.RGBAAt(x, y) != whole.RGBAAt(x, y) {
+				t.Fatalf("clipped blob differs at (%d,%d)", x, y)
+			}
+		}
+	}
+}
+
+var goldenPoints = sync.OnceValue(func() []enumerate.Point {
+	points, _ := enumerate.Generate(context.Background(), 9, 42)
+	return points
+})
+
+func TestGoldenImages(t *testing.T) {
+	base := Config{
+		Width: 300, Height: 200,
+		XMin: -3, YMin: -2, XMax: 3, YMax: 2,
+		MaxHeight: 9,
+		ColorBy:   "leading",
+		Text:      DefaultTextStyle,
+		Seed:      42,
+	}
+	viridis, err := LoadPalette("viridis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		setup func(*Config)
+	}{
+		{"leading", func(*Config) {}},
+		{"mahler-viridis", func(c *Config) {
+			c.ColorBy = "mahler"
+			c.Palette = viridis
+		}},
+		{"degree-zoomed", func(c *Config) {
+			c.ColorBy = "degree"
+			c.XMin, c.YMin, c.XMax, c.YMax = -1.5, -0.5, 0, 0.5
+		}},
+		{"overlays", func(c *Config) {
+			c.Overlays = Overlays{Legend: true, Axes: true, UnitCircle: true}
+		}},
+		{"highlights", func(c *Config) {
+			all := map[roots.Kind]bool{roots.Pisot: true, roots.Salem: true, roots.SmallMeasure: true, roots.Cyclotomic: true}
+			c.Marks = enumerate.Marks(enumerate.Classify(goldenPoints(), enumerate.DefaultSmallMeasure, 0), all)
+			c.Overlays = Overlays{Legend: true}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.setup(&config)
+			img := Image(goldenPoints(), config)
+			path := filepath.Join("testdata", tt.name+".png")
+			if *update {
+				if err := writePNG(path, img); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := readPNG(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			compareImages(t, img, want)
+		})
+	}
+}
+
+// compareImages fails if got and want differ in more than a few pixels. The roots may
+// differ in their last bits across platforms, which can move a blob by a pixel.
+func TestVectorOverlays(t *testing.T) {
+	// Vector output draws the unit circle, and refuses the overlays it would leave out
+	config := Config{Width: 120, Height: 80, XMin: -3, YMin: -2, XMax: 3, YMax: 2, MaxHeight: 9, ColorBy: "leading"}
+	for _, write := range []struct {
+		name string
+		f    func(io.Writer, []enumerate.Point, Config) error
+	}{{"SVG", SVG}, {"PDF", PDF}} {
+		for _, o := range []Overlays{{Legend: true}, {Axes: true}, {Caption: true, UnitCircle: true}} {
+			c := config
+			c.Overlays = o
+			if err := write.f(io.Discard, goldenPoints(), c); err == nil {
+				t.Errorf("%s drew overlays %+v", write.name, o)
+			}
+		}
+		c := config
+		c.Overlays = Overlays{UnitCircle: true}
+		if err := write.f(io.Discard, goldenPoints(), c); err != nil {
+			t.Errorf("%s with the unit circle: %v", write.name, err)
+		}
+	}
+}
+
+func compareImages(t *testing.T, got *image.RGBA, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("image is %v, want %v", got.Bounds(), want.Bounds())
+	}
+	b := got.Bounds()
+	diff := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if got.At(x, y) != color.RGBAModel.Convert(want.At(x, y)) {
+				diff++
+			}
+		}
+	}
+	if limit := b.Dx() * b.Dy() / 1000; diff > limit {
+		t.Errorf("%d pixels differ from the golden image, more than %d", diff, limit)
+	}
+}
+
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func BenchmarkDrawBlob(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+	for _, radius := range []float64{3, 10, 40, 80} {
+		b.Run(fmt.Sprintf("radius-%g", radius), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				drawBlob(img, 200, 200, radius, color.RGBA{200, 120, 40, 255})
+			}
+		})
+	}
+}
+
+func BenchmarkImage(b *testing.B) {
+	points := goldenPoints()
+	config := Config{Width: 1200, Height: 800, XMin: -3, YMin: -2, XMax: 3, YMax: 2, MaxHeight: 9, ColorBy: "leading"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Image(points, config)
+	}
+}
+
+// BenchmarkPipeline enumerates, renders and encodes a PNG, as a plain run does
+func BenchmarkPipeline(b *testing.B) {
+	for _, h := range []int{10, 12} {
+		b.Run(fmt.Sprintf("height-%d", h), func(b *testing.B) {
+			config := Config{Width: 1200, Height: 800, XMin: -3, YMin: -2, XMax: 3, YMax: 2, MaxHeight: h, ColorBy: "leading"}
+			for i := 0; i < b.N; i++ {
+				points, enum := enumerate.Generate(context.Background(), h, 1)
+				config.Seed = enum.Seed
+				config.Run = &RunInfo{Enumeration: enum}
+				if err := PNG(io.Discard, points, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
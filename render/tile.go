@@ -0,0 +1,373 @@
+package render
+
+import (
+	"bufio"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/internal/logging"
+)
+
+// tileGrid splits a config.Width x config.Height canvas into square tiles
+type tileGrid struct {
+	TileSize   int
+	Cols, Rows int
+}
+
+// newTileGrid covers the canvas with tiles of the given size; edge tiles may be smaller
+func newTileGrid(config Config, tileSize int) tileGrid {
+	return tileGrid{
+		TileSize: tileSize,
+		Cols:     (config.Width + tileSize - 1) / tileSize,
+		Rows:     (config.Height + tileSize - 1) / tileSize,
+	}
+}
+
+// rect returns the canvas pixels covered by tile (col, row)
+func (g tileGrid) rect(col, row int, config Config) image.Rectangle {
+	r := image.Rect(col*g.TileSize, row*g.TileSize, (col+1)*g.TileSize, (row+1)*g.TileSize)
+	return r.Intersect(image.Rect(0, 0, config.Width, config.Height))
+}
+
+// bucketPoints lists, for every tile, the points whose blobs reach into it,
+// so each tile only draws its own neighbourhood
+func (g tileGrid) bucketPoints(points []enumerate.Point, config Config) [][]enumerate.Point {
+	buckets := make([][]enumerate.Point, g.Cols*g.Rows)
+	for _, p := range points {
+		x, y := real(p.Z), imag(p.Z)
+		if x < config.XMin || x > config.XMax || y < config.YMin || y > config.YMax {
+			continue
+		}
+		sx, sy := worldToScreen(x, y, config)
+		reach := blobRadius(p.H, config) + 5 // Matches the square drawBlob visits
+		c0 := max(0, int((sx-reach)/float64(g.TileSize)))
+		c1 := min(g.Cols-1, int((sx+reach)/float64(g.TileSize)))
+		r0 := max(0, int((sy-reach)/float64(g.TileSize)))
+		r1 := min(g.Rows-1, int((sy+reach)/float64(g.TileSize)))
+		for row := r0; row <= r1; row++ {
+			for col := c0; col <= c1; col++ {
+				buckets[row*g.Cols+col] = append(buckets[row*g.Cols+col], p)
+			}
+		}
+	}
+	return buckets
+}
+
+// renderTiles renders the given tiles in parallel and passes each to emit as it completes.
+// Only the unit-circle overlay and the marks are drawn; the others are laid out for a whole image.
+// Once ctx is cancelled the remaining tiles are skipped.
+func renderTiles(ctx context.Context, g tileGrid, buckets [][]enumerate.Point, tiles []int, colorer Colorer, config Config, emit func(tile int, img *image.RGBA) error) error {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range work {
+				if ctx.Err() != nil {
+					continue
+				}
+				img := image.NewRGBA(g.rect(t%g.Cols, t/g.Cols, config))
+				DrawPoints(img, buckets[t], colorer, config)
+				if config.Overlays.UnitCircle {
+					DrawUnitCircle(img, config)
+				}
+				drawMarks(img, config)
+				if err := emit(t, img); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, t := range tiles {
+		work <- t
+	}
+	close(work)
+	wg.Wait()
+	return firstErr
+}
+
+// tileManifest describes a directory of tiles
+type tileManifest struct {
+	Width, Height int
+	TileSize      int
+	Cols, Rows    int
+	XMin, YMin    float64
+	XMax, YMax    float64
+	Pattern       string // Tile filename, formatted with row then column
+	Interrupted   string `json:",omitempty"` // Set when the run was stopped; only the tiles on disk were written
+}
+
+const tileFilePattern = "tile_%04d_%04d.png"
+
+// TiledDir writes the canvas as a grid of PNG tiles plus a tiles.json manifest.
+// When ctx is cancelled no more tiles are started, and the manifest says the grid is incomplete.
+func TiledDir(ctx context.Context, points []enumerate.Point, config Config, tileSize int) error {
+	log := logging.Or(config.Logger)
+	dir := config.OutputFile
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create tile directory: %v", err)
+	}
+
+	g := newTileGrid(config, tileSize)
+	log.Info("Rendering tiles", "width", config.Width, "height", config.Height, "cols", g.Cols, "rows", g.Rows, "tile_size", tileSize)
+	buckets := g.bucketPoints(points, config)
+	colorer := NewColorer(config.ColorBy, points, config)
+
+	tiles := make([]int, g.Cols*g.Rows)
+	for i := range tiles {
+		tiles[i] = i
+	}
+	var done int
+	var mu sync.Mutex
+	err := renderTiles(ctx, g, buckets, tiles, colorer, config, func(t int, img *image.RGBA) error {
+		path := filepath.Join(dir, fmt.Sprintf(tileFilePattern, t/g.Cols, t%g.Cols))
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create tile: %v", err)
+		}
+		defer file.Close()
+		if err := EncodePNGWithChunks(file, img, OutputChunks(config)); err != nil {
+			return fmt.Errorf("failed to encode tile %s: %v", path, err)
+		}
+
+		mu.Lock()
+		done++
+		if done%100 == 0 || done == len(tiles) {
+			log.Info("Wrote tiles", "done", done, "tiles", len(tiles))
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m := tileManifest{
+		Width: config.Width, Height: config.Height, TileSize: tileSize, Cols: g.Cols, Rows: g.Rows,
+		XMin: config.XMin, YMin: config.YMin, XMax: config.XMax, YMax: config.YMax,
+		Pattern: tileFilePattern,
+	}
+	if done < len(tiles) {
+		m.Interrupted = fmt.Sprintf("%d of %d tiles written", done, len(tiles))
+		log.Warn("Interrupted: " + m.Interrupted)
+	} else if config.Run != nil && config.Run.StoppedAt > 0 {
+		m.Interrupted = enumerate.DescribeStop(config.Run.StoppedAt)
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tiles.json"), append(manifest, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+
+	log.Info("Saved tiles", "path", dir)
+	return nil
+}
+
+// TiledPNG streams the canvas into a single PNG one band of tiles at a time,
+// so memory use is bounded by config.Width x tileSize pixels. When ctx is cancelled the
+// remaining bands are left black, so the file is still a complete PNG.
+func TiledPNG(ctx context.Context, points []enumerate.Point, config Config, tileSize int) error {
+	log := logging.Or(config.Logger)
+	file, err := os.Create(config.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer file.Close()
+
+	g := newTileGrid(config, tileSize)
+	log.Info("Streaming image", "width", config.Width, "height", config.Height, "bands", g.Rows, "band_rows", tileSize)
+	buckets := g.bucketPoints(points, config)
+	colorer := NewColorer(config.ColorBy, points, config)
+
+	out := bufio.NewWriterSize(file, 1<<20)
+	pw, err := newPNGStreamWriter(out, config.Width, config.Height, OutputChunks(config))
+	if err != nil {
+		return err
+	}
+
+	interrupted := ""
+	for row := 0; row < g.Rows; row++ {
+		band := image.NewRGBA(image.Rect(0, row*tileSize, config.Width, min(config.Height, (row+1)*tileSize)))
+		tiles := make([]int, g.Cols)
+		for col := range tiles {
+			tiles[col] = row*g.Cols + col
+		}
+		var rendered atomic.Int64
+		err := renderTiles(ctx, g, buckets, tiles, colorer, config, func(t int, img *image.RGBA) error {
+			copyRect(band, img)
+			rendered.Add(1)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for y := band.Rect.Min.Y; y < band.Rect.Max.Y; y++ {
+			if err := pw.writeRow(band.Pix[band.PixOffset(0, y) : band.PixOffset(0, y)+4*config.Width]); err != nil {
+				return fmt.Errorf("failed to write PNG: %v", err)
+			}
+		}
+		if int(rendered.Load()) == len(tiles) {
+			log.Info("Wrote band", "band", row+1, "bands", g.Rows)
+		} else if interrupted == "" {
+			interrupted = fmt.Sprintf("tiles missing from band %d of %d on, left black", row+1, g.Rows)
+			log.Warn("Interrupted: " + interrupted)
+		}
+	}
+
+	// The header was written before the interrupt, so a note on it goes after the image data
+	var trailer []PNGChunk
+	if interrupted != "" {
+		trailer = append(trailer, PNGChunk{"tEXt", []byte("Partial\x00" + interrupted)})
+	}
+	if err := pw.close(trailer); err != nil {
+		return fmt.Errorf("failed to write PNG: %v", err)
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write PNG: %v", err)
+	}
+	log.Info("Saved image", "path", config.OutputFile)
+	return nil
+}
+
+// copyRect copies src into dst at src's own coordinates; disjoint tiles can be copied concurrently
+func copyRect(dst, src *image.RGBA) {
+	r := src.Rect.Intersect(dst.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(dst.Pix[dst.PixOffset(r.Min.X, y):dst.PixOffset(r.Max.X, y)], src.Pix[src.PixOffset(r.Min.X, y):src.PixOffset(r.Max.X, y)])
+	}
+}
+
+// pngStreamWriter writes an 8-bit RGB PNG row by row without holding the image in memory
+type pngStreamWriter struct {
+	w     io.Writer
+	idat  *idatWriter
+	z     *zlib.Writer
+	width int
+	row   []byte
+}
+
+// newPNGStreamWriter writes the signature, IHDR and the given ancillary chunks
+func newPNGStreamWriter(w io.Writer, width, height int, chunks []PNGChunk) (*pngStreamWriter, error) {
+	if _, err := w.Write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+		return nil, err
+	}
+	var ihdr [13]byte
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8] = 8 // Bit depth
+	ihdr[9] = 2 // Color type: truecolor
+	if err := writePNGChunk(w, "IHDR", ihdr[:]); err != nil {
+		return nil, err
+	}
+	for _, c := range chunks {
+		if err := writePNGChunk(w, c.Type, c.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	idat := &idatWriter{w: w}
+	return &pngStreamWriter{
+		w:     w,
+		idat:  idat,
+		z:     zlib.NewWriter(idat),
+		width: width,
+		row:   make([]byte, 1+3*width),
+	}, nil
+}
+
+// writeRow appends one row given as RGBA pixels; alpha is dropped
+func (p *pngStreamWriter) writeRow(rgba []byte) error {
+	p.row[0] = 1 // Sub filter: mostly-black rows compress far better as deltas
+	for x := 0; x < p.width; x++ {
+		for c := 0; c < 3; c++ {
+			v := rgba[4*x+c]
+			if x > 0 {
+				v -= rgba[4*(x-1)+c]
+			}
+			p.row[1+3*x+c] = v
+		}
+	}
+	_, err := p.z.Write(p.row)
+	return err
+}
+
+// close finishes the compressed stream and writes the trailer chunks and IEND
+func (p *pngStreamWriter) close(trailer []PNGChunk) error {
+	if err := p.z.Close(); err != nil {
+		return err
+	}
+	if err := p.idat.flush(); err != nil {
+		return err
+	}
+	for _, c := range trailer {
+		if err := writePNGChunk(p.w, c.Type, c.Data); err != nil {
+			return err
+		}
+	}
+	return writePNGChunk(p.w, "IEND", nil)
+}
+
+// idatWriter splits the zlib stream into IDAT chunks of at most 1 MiB
+type idatWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (d *idatWriter) Write(b []byte) (int, error) {
+	const chunkSize = 1 << 20
+	d.buf = append(d.buf, b...)
+	for len(d.buf) >= chunkSize {
+		if err := writePNGChunk(d.w, "IDAT", d.buf[:chunkSize]); err != nil {
+			return 0, err
+		}
+		d.buf = d.buf[chunkSize:]
+	}
+	return len(b), nil
+}
+
+func (d *idatWriter) flush() error {
+	if len(d.buf) == 0 {
+		return nil
+	}
+	err := writePNGChunk(d.w, "IDAT", d.buf)
+	d.buf = nil
+	return err
+}
+
+// ParseSize parses "WxH", e.g. "65536x65536"
+func ParseSize(s string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size %q: want WxH", s)
+	}
+	w, err1 := strconv.Atoi(ws)
+	h, err2 := strconv.Atoi(hs)
+	if err1 != nil || err2 != nil || w < 1 || h < 1 {
+		return 0, 0, fmt.Errorf("invalid size %q: want WxH", s)
+	}
+	return w, h, nil
+}
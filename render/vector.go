@@ -0,0 +1,491 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/internal/logging"
+)
+
+// vectorBlob is one point as it appears in vector output, in the same pixel space as the raster render
+type vectorBlob struct {
+	X, Y, R float64
+	Color   color.RGBA
+}
+
+// blobExtent is how far a blob reaches, in multiples of its radius, before drawBlob's 0.005 intensity cutoff:
+// sigma = radius/2.5 and exp(-d²/2σ²) = 0.005 at d = σ·sqrt(2 ln 200)
+var blobExtent = math.Sqrt(2*math.Log(200)) / 2.5
+
+// vectorBlobs applies the viewport, coloring and radius rules of Image
+func vectorBlobs(points []enumerate.Point, config Config) []vectorBlob {
+	colorer := NewColorer(config.ColorBy, points, config)
+
+	var blobs []vectorBlob
+	for _, point := range points {
+		x, y := real(point.Z), imag(point.Z)
+		if x < config.XMin || x > config.XMax || y < config.YMin || y > config.YMax {
+			continue
+		}
+		sx, sy := worldToScreen(x, y, config)
+		blobs = append(blobs, vectorBlob{X: sx, Y: sy, R: blobRadius(point.H, config), Color: colorer.Color(point)})
+	}
+	return blobs
+}
+
+// contourLayer is a set of polygons filled with one color: the part of the plane where
+// the blob density exceeds a level and the blended color falls in one color bucket
+type contourLayer struct {
+	Color   color.RGBA
+	Opacity float64
+	Polys   [][][2]float64
+}
+
+const (
+	contourCell = 4 // Density grid spacing in pixels
+)
+
+// contourLevels are the density thresholds, in units of a single blob's peak intensity
+var contourLevels = []float64{0.05, 0.1, 0.2, 0.4, 0.7, 1.0}
+
+// densityContours replaces individual blobs with filled density contours when there are too many to draw.
+// Blobs are splatted onto a coarse grid exactly as drawBlob would, then each level is traced with
+// marching squares; cells are grouped by their blended color so regions keep their hue.
+func densityContours(blobs []vectorBlob, config Config) []contourLayer {
+	gw, gh := config.Width/contourCell+2, config.Height/contourCell+2
+	density := make([]float64, gw*gh)
+	rgb := make([][3]float64, gw*gh)
+
+	for _, b := range blobs {
+		sigma := b.R / 2.5
+		reach := b.R * blobExtent
+		x0, x1 := max(0, int((b.X-reach)/contourCell)), min(gw-1, int((b.X+reach)/contourCell)+1)
+		y0, y1 := max(0, int((b.Y-reach)/contourCell)), min(gh-1, int((b.Y+reach)/contourCell)+1)
+		for gy := y0; gy <= y1; gy++ {
+			for gx := x0; gx <= x1; gx++ {
+				dx, dy := float64(gx*contourCell)-b.X, float64(gy*contourCell)-b.Y
+				intensity := math.Exp(-(dx*dx + dy*dy) / (2 * sigma * sigma))
+				if intensity < 0.005 {
+					continue
+				}
+				i := gy*gw + gx
+				density[i] += intensity
+				rgb[i][0] += float64(b.Color.R) * intensity
+				rgb[i][1] += float64(b.Color.G) * intensity
+				rgb[i][2] += float64(b.Color.B) * intensity
+			}
+		}
+	}
+
+	// Smooth the color sums so neighboring cells of mixed hue fall in the same bucket
+	smooth := make([][3]float64, len(rgb))
+	for gy := 0; gy < gh; gy++ {
+		for gx := 0; gx < gw; gx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := gx+dx, gy+dy
+					if nx < 0 || nx >= gw || ny < 0 || ny >= gh {
+						continue
+					}
+					for c := 0; c < 3; c++ {
+						smooth[gy*gw+gx][c] += rgb[ny*gw+nx][c] / 9
+					}
+				}
+			}
+		}
+	}
+
+	// bucket returns the additive blend at a grid node, clamped like the raster renderer so dense
+	// regions turn white, then brightened to full value (the stacked levels supply the brightness)
+	// and quantized to 2 bits per channel
+	bucket := func(i int) color.RGBA {
+		var c [3]float64
+		for k := range c {
+			c[k] = math.Min(255, smooth[i][k])
+		}
+		peak := math.Max(c[0], math.Max(c[1], c[2]))
+		if peak == 0 {
+			return color.RGBA{255, 255, 255, 255}
+		}
+		q := func(v float64) uint8 { return uint8(math.Round(v/peak*3) * 85) }
+		return color.RGBA{q(c[0]), q(c[1]), q(c[2]), 255}
+	}
+
+	var layers []contourLayer
+	for _, level := range contourLevels {
+		byColor := map[color.RGBA]*contourLayer{}
+		add := func(c color.RGBA, poly [][2]float64) {
+			layer, ok := byColor[c]
+			if !ok {
+				layer = &contourLayer{Color: c, Opacity: 1 / float64(len(contourLevels))}
+				byColor[c] = layer
+			}
+			layer.Polys = append(layer.Polys, poly)
+		}
+
+		for gy := 0; gy < gh-1; gy++ {
+			runStart, runColor := -1, color.RGBA{}
+			flush := func(end int) {
+				if runStart >= 0 {
+					x0, x1 := float64(runStart*contourCell), float64(end*contourCell)
+					y0, y1 := float64(gy*contourCell), float64((gy+1)*contourCell)
+					add(runColor, [][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}})
+					runStart = -1
+				}
+			}
+
+			for gx := 0; gx < gw-1; gx++ {
+				corners := [4]int{gy*gw + gx, gy*gw + gx + 1, (gy+1)*gw + gx + 1, (gy+1)*gw + gx}
+				inside := 0
+				for _, c := range corners {
+					if density[c] >= level {
+						inside++
+					}
+				}
+				c := bucket(corners[0])
+
+				// Merge runs of fully covered cells into rectangles
+				if inside == 4 {
+					if runStart >= 0 && c != runColor {
+						flush(gx)
+					}
+					if runStart < 0 {
+						runStart, runColor = gx, c
+					}
+					continue
+				}
+				flush(gx)
+				if inside == 0 {
+					continue
+				}
+				add(c, marchingSquaresCell(gx, gy, corners, density, level))
+			}
+			flush(gw - 1)
+		}
+
+		// Deterministic layer order
+		keys := make([]color.RGBA, 0, len(byColor))
+		for c := range byColor {
+			keys = append(keys, c)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keys[i], keys[j]
+			return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+		})
+		for _, c := range keys {
+			layers = append(layers, *byColor[c])
+		}
+	}
+	return layers
+}
+
+// marchingSquaresCell returns the part of grid cell (gx, gy) where the interpolated density is at least level.
+// corners are the grid indices of the top-left, top-right, bottom-right and bottom-left nodes.
+func marchingSquaresCell(gx, gy int, corners [4]int, density []float64, level float64) [][2]float64 {
+	offsets := [4][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	var poly [][2]float64
+	for i := 0; i < 4; i++ {
+		j := (i + 1) % 4
+		vi, vj := density[corners[i]], density[corners[j]]
+		pi := [2]float64{float64(gx) + offsets[i][0], float64(gy) + offsets[i][1]}
+		pj := [2]float64{float64(gx) + offsets[j][0], float64(gy) + offsets[j][1]}
+		if vi >= level {
+			poly = append(poly, [2]float64{pi[0] * contourCell, pi[1] * contourCell})
+		}
+		if (vi >= level) != (vj >= level) {
+			t := (level - vi) / (vj - vi)
+			poly = append(poly, [2]float64{
+				(pi[0] + t*(pj[0]-pi[0])) * contourCell,
+				(pi[1] + t*(pj[1]-pi[1])) * contourCell,
+			})
+		}
+	}
+	return poly
+}
+
+// useContours reports whether there are too many blobs to draw individually
+func useContours(blobs []vectorBlob, config Config) bool {
+	return config.VectorMaxPoints > 0 && len(blobs) > config.VectorMaxPoints
+}
+
+// hexRGB formats a color as #rrggbb
+func hexRGB(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// checkVectorOverlays rejects the overlays vector output does not draw, rather than leave
+// them out of a file that should match the PNG
+func checkVectorOverlays(config Config) error {
+	if o := config.Overlays; o.Legend || o.Axes || o.Caption {
+		return fmt.Errorf("vector output draws only the unit-circle overlay, not the legend, axes or caption")
+	}
+	return nil
+}
+
+// SVG writes the points as SVG, each a circle with a gaussian radial gradient,
+// or as density contours when there are more than config.VectorMaxPoints. Of the
+// overlays it draws the unit circle and the marks, and the others are an error.
+func SVG(w io.Writer, points []enumerate.Point, config Config) error {
+	if err := checkVectorOverlays(config); err != nil {
+		return err
+	}
+	log := logging.Or(config.Logger)
+	blobs := vectorBlobs(points, config)
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	// With a DPI the drawing keeps its pixel coordinates but gets a physical size
+	width, height := strconv.Itoa(config.Width), strconv.Itoa(config.Height)
+	if config.DPI > 0 {
+		width = strconv.FormatFloat(float64(config.Width)/config.DPI, 'g', 6, 64) + "in"
+		height = strconv.FormatFloat(float64(config.Height)/config.DPI, 'g', 6, 64) + "in"
+	}
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %d %d\">\n",
+		width, height, config.Width, config.Height)
+	fmt.Fprintf(out, "<desc>\n")
+	for _, t := range OutputMetadata(config) {
+		fmt.Fprintf(out, "%s=%s\n", xmlEscape(t.Key), xmlEscape(t.Value))
+	}
+	fmt.Fprintf(out, "</desc>\n")
+	// Screen blending is the closest widely supported mode to the raster renderer's additive blending
+	fmt.Fprintf(out, "<style>.b{mix-blend-mode:screen}</style>\n")
+	fmt.Fprintf(out, "<rect width=\"%d\" height=\"%d\" fill=\"#000000\"/>\n", config.Width, config.Height)
+
+	if useContours(blobs, config) {
+		log.Info("Drawing density contours", "points", len(blobs))
+		for _, layer := range densityContours(blobs, config) {
+			fmt.Fprintf(out, "<path class=\"b\" fill=\"%s\" opacity=\"%.3f\" d=\"", hexRGB(layer.Color), layer.Opacity)
+			for _, poly := range layer.Polys {
+				for i, p := range poly {
+					cmd := "L"
+					if i == 0 {
+						cmd = "M"
+					}
+					fmt.Fprintf(out, "%s%.1f %.1f", cmd, p[0], p[1])
+				}
+				fmt.Fprintf(out, "Z")
+			}
+			fmt.Fprintf(out, "\"/>\n")
+		}
+	} else {
+		log.Info("Drawing SVG circles", "points", len(blobs))
+
+		// One gaussian gradient per color, sampled at evenly spaced radii
+		gradients := map[color.RGBA]int{}
+		fmt.Fprintf(out, "<defs>\n")
+		for _, b := range blobs {
+			if _, ok := gradients[b.Color]; ok {
+				continue
+			}
+			id := len(gradients)
+			gradients[b.Color] = id
+			fmt.Fprintf(out, "<radialGradient id=\"g%d\">", id)
+			const stops = 8
+			for s := 0; s <= stops; s++ {
+				t := float64(s) / stops
+				d := t * blobExtent * 2.5 // Distance in units of sigma
+				fmt.Fprintf(out, "<stop offset=\"%.3f\" stop-color=\"%s\" stop-opacity=\"%.3f\"/>",
+					t, hexRGB(b.Color), math.Exp(-d*d/2))
+			}
+			fmt.Fprintf(out, "</radialGradient>\n")
+		}
+		fmt.Fprintf(out, "</defs>\n")
+
+		for _, b := range blobs {
+			fmt.Fprintf(out, "<circle class=\"b\" cx=\"%.2f\" cy=\"%.2f\" r=\"%.2f\" fill=\"url(#g%d)\"/>\n",
+				b.X, b.Y, b.R*blobExtent, gradients[b.Color])
+		}
+	}
+
+	if config.Overlays.UnitCircle {
+		cx, cy := worldToScreen(0, 0, config)
+		rx, ry := worldToScreen(1, 1, config)
+		fmt.Fprintf(out, "<ellipse cx=\"%.2f\" cy=\"%.2f\" rx=\"%.2f\" ry=\"%.2f\" fill=\"none\" stroke=\"%s\"/>\n",
+			cx, cy, rx-cx, cy-ry, hexRGB(overlayLine))
+	}
+	svgMarks(out, config)
+
+	fmt.Fprintf(out, "</svg>\n")
+	return out.Flush()
+}
+
+// xmlEscape escapes text for element content and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// pdfDiscs approximates a gaussian blob in PDF as concentric discs at these intensities,
+// each painted with pdfDiscAlpha in Screen blend mode
+var pdfDiscs = []float64{0.05, 0.25, 0.5, 0.8}
+
+const pdfDiscAlpha = 0.35
+
+// pdfEllipse appends a closed ellipse path built from four Bézier curves
+func pdfEllipse(b *bytes.Buffer, x, y, rx, ry float64) {
+	const k = 0.5523 // Control point distance for a quarter circle
+	fmt.Fprintf(b, "%.2f %.2f m\n", x+rx, y)
+	fmt.Fprintf(b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x+rx, y+k*ry, x+k*rx, y+ry, x, y+ry)
+	fmt.Fprintf(b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x-k*rx, y+ry, x-rx, y+k*ry, x-rx, y)
+	fmt.Fprintf(b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x-rx, y-k*ry, x-k*rx, y-ry, x, y-ry)
+	fmt.Fprintf(b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x+k*rx, y-ry, x+rx, y-k*ry, x+rx, y)
+}
+
+// pdfColor sets the fill color if it differs from the current one
+func pdfColor(b *bytes.Buffer, current *color.RGBA, c color.RGBA) {
+	if *current != c {
+		fmt.Fprintf(b, "%.3f %.3f %.3f rg\n", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+		*current = c
+	}
+}
+
+// PDF writes a single-page PDF, one pixel per page unit unless config.DPI is set, drawing each point as
+// translucent concentric discs, or density contours when there are more than config.VectorMaxPoints.
+// Of the overlays it draws the unit circle and the marks, and the others are an error.
+func PDF(w io.Writer, points []enumerate.Point, config Config) error {
+	if err := checkVectorOverlays(config); err != nil {
+		return err
+	}
+	log := logging.Or(config.Logger)
+	blobs := vectorBlobs(points, config)
+	height := float64(config.Height)
+
+	// Page content; PDF's origin is the bottom-left corner, so y is flipped.
+	// With a DPI the page is scaled from pixels to its printed size in points.
+	var content bytes.Buffer
+	pageW, pageH := float64(config.Width), float64(config.Height)
+	if config.DPI > 0 {
+		s := 72 / config.DPI
+		pageW, pageH = pageW*s, pageH*s
+		fmt.Fprintf(&content, "%.6f 0 0 %.6f 0 0 cm\n", s, s)
+	}
+	fmt.Fprintf(&content, "0 0 0 rg 0 0 %d %d re f\n", config.Width, config.Height)
+	current := color.RGBA{0, 0, 0, 255}
+	var alphas []float64
+
+	if useContours(blobs, config) {
+		log.Info("Drawing density contours", "points", len(blobs))
+		for _, layer := range densityContours(blobs, config) {
+			fmt.Fprintf(&content, "/A%d gs\n", pdfAlphaIndex(&alphas, layer.Opacity))
+			pdfColor(&content, &current, layer.Color)
+			for _, poly := range layer.Polys {
+				for i, p := range poly {
+					op := "l"
+					if i == 0 {
+						op = "m"
+					}
+					fmt.Fprintf(&content, "%.1f %.1f %s\n", p[0], height-p[1], op)
+				}
+				fmt.Fprintf(&content, "h\n")
+			}
+			fmt.Fprintf(&content, "f\n")
+		}
+	} else {
+		log.Info("Drawing PDF discs", "points", len(blobs))
+
+		// Screen blending is order-independent, so draw all discs of one size class together, sorted by color
+		sorted := append([]vectorBlob(nil), blobs...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := sorted[i].Color, sorted[j].Color
+			return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+		})
+		fmt.Fprintf(&content, "/A%d gs\n", pdfAlphaIndex(&alphas, pdfDiscAlpha))
+		for _, intensity := range pdfDiscs {
+			for _, b := range sorted {
+				sigma := b.R / 2.5
+				r := sigma * math.Sqrt(2*math.Log(1/intensity))
+				pdfColor(&content, &current, b.Color)
+				pdfEllipse(&content, b.X, height-b.Y, r, r)
+				fmt.Fprintf(&content, "f\n")
+			}
+		}
+	}
+
+	if config.Overlays.UnitCircle {
+		cx, cy := worldToScreen(0, 0, config)
+		rx, ry := worldToScreen(1, 1, config)
+		fmt.Fprintf(&content, "/A%d gs\n", pdfAlphaIndex(&alphas, 1))
+		fmt.Fprintf(&content, "%.3f G 1 w\n", float64(overlayLine.R)/255)
+		pdfEllipse(&content, cx, height-cy, rx-cx, cy-ry)
+		fmt.Fprintf(&content, "S\n")
+	}
+	if len(config.Marks) > 0 {
+		fmt.Fprintf(&content, "/A%d gs\n", pdfAlphaIndex(&alphas, 1))
+		pdfMarks(&content, config, height)
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(content.Bytes()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	// Transparency states referenced from the content
+	var gs strings.Builder
+	for i, a := range alphas {
+		fmt.Fprintf(&gs, "/A%d << /ca %.3f /CA %.3f /BM /Screen >> ", i, a, a)
+	}
+
+	var info strings.Builder
+	for _, t := range OutputMetadata(config) {
+		fmt.Fprintf(&info, "%s=%s; ", t.Key, t.Value)
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents 4 0 R /Resources << /ExtGState << %s>> >> >>",
+			pageW, pageH, gs.String()),
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.String()),
+		fmt.Sprintf("<< /Producer %s /Keywords %s >>", pdfString("algebraic_vis"), pdfString(strings.TrimSuffix(info.String(), "; "))),
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, len(objects), xref)
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// pdfAlphaIndex returns the index of the graphics state with the given alpha, adding it if needed
+func pdfAlphaIndex(alphas *[]float64, alpha float64) int {
+	for i, a := range *alphas {
+		if a == alpha {
+			return i
+		}
+	}
+	*alphas = append(*alphas, alpha)
+	return len(*alphas) - 1
+}
+
+// pdfString quotes a PDF literal string
+func pdfString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return "(" + r.Replace(s) + ")"
+}
@@ -0,0 +1,147 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParseViewport sets the viewport from the x_min y_min x_max y_max positional arguments
+func ParseViewport(args []string, config *Config) error {
+	var err error
+	if config.XMin, err = strconv.ParseFloat(args[0], 64); err != nil {
+		return fmt.Errorf("invalid x_min: %v", err)
+	}
+	if config.YMin, err = strconv.ParseFloat(args[1], 64); err != nil {
+		return fmt.Errorf("invalid y_min: %v", err)
+	}
+	if config.XMax, err = strconv.ParseFloat(args[2], 64); err != nil {
+		return fmt.Errorf("invalid x_max: %v", err)
+	}
+	if config.YMax, err = strconv.ParseFloat(args[3], 64); err != nil {
+		return fmt.Errorf("invalid y_max: %v", err)
+	}
+
+	if config.XMin >= config.XMax || config.YMin >= config.YMax {
+		return fmt.Errorf("invalid rectangle. x_min must be < x_max and y_min must be < y_max")
+	}
+	return nil
+}
+
+// AspectModes lists the values accepted by --aspect
+const AspectModes = "pad, fit, stretch"
+
+// FitAspect matches the viewport's aspect ratio to the image's so circles render as circles.
+// "pad" widens the viewport about its center until the image is covered, keeping all of the
+// requested rectangle in view; "fit" trims it until the requested rectangle fills the image;
+// "stretch" leaves it alone and scales x and y independently.
+func FitAspect(config Config, mode string) (Config, error) {
+	aspect := float64(config.Width) / float64(config.Height)
+	switch mode {
+	case "pad":
+		return PadViewport(config, aspect), nil
+	case "fit":
+		return trimViewport(config, aspect), nil
+	case "stretch":
+		return config, nil
+	}
+	return config, fmt.Errorf("unknown aspect mode %q (choose from %s)", mode, AspectModes)
+}
+
+// PadViewport widens the shorter side of the viewport about its center to the given width/height ratio
+func PadViewport(config Config, aspect float64) Config {
+	w, h := config.XMax-config.XMin, config.YMax-config.YMin
+	if w/h < aspect {
+		return resizeViewport(config, h*aspect, h)
+	}
+	return resizeViewport(config, w, w/aspect)
+}
+
+// trimViewport shrinks the longer side of the viewport about its center to the given width/height ratio
+func trimViewport(config Config, aspect float64) Config {
+	w, h := config.XMax-config.XMin, config.YMax-config.YMin
+	if w/h > aspect {
+		return resizeViewport(config, h*aspect, h)
+	}
+	return resizeViewport(config, w, w/aspect)
+}
+
+// resizeViewport gives the viewport a new width and height, keeping its center
+func resizeViewport(config Config, w, h float64) Config {
+	if w == config.XMax-config.XMin && h == config.YMax-config.YMin {
+		return config // Avoid rounding the corners of an unchanged viewport
+	}
+	cx, cy := (config.XMin+config.XMax)/2, (config.YMin+config.YMax)/2
+	config.XMin, config.XMax = cx-w/2, cx+w/2
+	config.YMin, config.YMax = cy-h/2, cy+h/2
+	return config
+}
+
+// ViewOptions are the alternatives to positional corners for choosing the viewport
+type ViewOptions struct {
+	Center string  // Complex number at the middle of the view
+	Span   float64 // Width of the view in the complex plane; 0 keeps the current width
+	Zoom   float64 // Magnification about the center; 0 or 1 leaves the size alone
+	Preset string  // Name of a Preset supplying center and span
+}
+
+// ApplyView sets the viewport from positional corners, a preset or a center, then applies
+// span and zoom. A view given by center and span takes its height from the image shape.
+func ApplyView(config *Config, corners []string, view ViewOptions, presets map[string]Preset) error {
+	sources := 0
+	for _, given := range []bool{len(corners) > 0, view.Center != "", view.Preset != ""} {
+		if given {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("choose the view with only one of corners, --center and --preset")
+	}
+	if view.Span < 0 || view.Zoom < 0 {
+		return fmt.Errorf("span and zoom must be positive")
+	}
+
+	if len(corners) > 0 {
+		if err := ParseViewport(corners, config); err != nil {
+			return err
+		}
+	}
+
+	center := complex((config.XMin+config.XMax)/2, (config.YMin+config.YMax)/2)
+	span := view.Span
+	if view.Preset != "" {
+		p, ok := presets[view.Preset]
+		if !ok {
+			return fmt.Errorf("unknown preset %q (see --list-presets)", view.Preset)
+		}
+		center, _ = parseComplex(p.Center) // Checked when loaded
+		if span == 0 {
+			span = p.Span
+		}
+	}
+	if view.Center != "" {
+		var err error
+		if center, err = parseComplex(view.Center); err != nil {
+			return err
+		}
+	}
+
+	if view.Preset != "" || view.Center != "" || span > 0 {
+		if span == 0 {
+			span = config.XMax - config.XMin
+		}
+		*config = resizeViewport(*config, span, span*float64(config.Height)/float64(config.Width))
+		*config = moveViewport(*config, center)
+	}
+	if view.Zoom > 0 {
+		*config = resizeViewport(*config, (config.XMax-config.XMin)/view.Zoom, (config.YMax-config.YMin)/view.Zoom)
+	}
+	return nil
+}
+
+// moveViewport centers the viewport on z, keeping its size
+func moveViewport(config Config, z complex128) Config {
+	w, h := config.XMax-config.XMin, config.YMax-config.YMin
+	config.XMin, config.XMax = real(z)-w/2, real(z)+w/2
+	config.YMin, config.YMax = imag(z)-h/2, imag(z)+h/2
+	return config
+}
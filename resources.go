@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// resourceFlags bound the CPU and memory a run uses. Like the profiling flags they are not
+// settings of a job.
+type resourceFlags struct {
+	workers     *int
+	memoryLimit *string
+}
+
+// addResourceFlags defines the resource flags on fs
+func addResourceFlags(fs *flag.FlagSet) *resourceFlags {
+	return &resourceFlags{
+		workers:     fs.Int("workers", 0, "Goroutines solving polynomials (0 = one per CPU)"),
+		memoryLimit: fs.String("memory-limit", "", "Soft limit on memory use, e.g. 8GiB"),
+	}
+}
+
+// printResourceUsage prints the help lines of the resource flags
+func printResourceUsage() {
+	fmt.Printf("  --workers N       Goroutines solving polynomials (default: number of CPUs, %d)\n", runtime.NumCPU())
+	fmt.Printf("  --memory-limit SIZE  Soft limit on memory use, e.g. 512MiB or 8GiB; the garbage collector works harder near it\n")
+}
+
+// apply checks the flags and sets the memory limit
+func (r *resourceFlags) apply() error {
+	if *r.workers < 0 {
+		return fmt.Errorf("workers must be at least 1, or 0 for one per CPU")
+	}
+	if *r.memoryLimit == "" {
+		return nil
+	}
+	limit, err := parseSize(*r.memoryLimit)
+	if err != nil {
+		return fmt.Errorf("memory-limit: %v", err)
+	}
+	debug.SetMemoryLimit(limit)
+	slog.Info("Memory limit set", "bytes", limit)
+	return nil
+}
+
+// sizeUnits are the suffixes parseSize accepts, longest first
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// parseSize reads a byte count like 1500000, 512MiB, 8GB or 1.5G
+func parseSize(s string) (int64, error) {
+	number, scale := s, int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(u.suffix)) {
+			number, scale = strings.TrimSpace(s[:len(s)-len(u.suffix)]), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512MiB or 8GiB)", s)
+	}
+	return int64(n * float64(scale)), nil
+}
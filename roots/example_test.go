@@ -0,0 +1,35 @@
+package roots_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+func ExampleFind() {
+	// x^2 - x - 1, constant term first
+	coeffs := []complex128{-1, -1, 1}
+	zs := roots.Find(coeffs, 2, rand.New(rand.NewSource(1)))
+	sort.Slice(zs, func(i, j int) bool { return real(zs[i]) < real(zs[j]) })
+	for _, z := range zs {
+		fmt.Printf("%.6f\n", real(z))
+	}
+	// Output:
+	// -0.618034
+	// 1.618034
+}
+
+func ExampleInvariants() {
+	// x^2 - x - 1 has the roots (1 ± √5)/2
+	zs := roots.Find([]complex128{-1, -1, 1}, 2, rand.New(rand.NewSource(1)))
+	disc, mahler := roots.Invariants(zs, 1)
+	fmt.Printf("|discriminant| %.4g, Mahler measure %.4g\n", disc, mahler)
+	// Output: |discriminant| 5, Mahler measure 1.618
+}
+
+func ExampleFormatPolynomial() {
+	fmt.Println(roots.FormatPolynomial([]int{1, 0, -1, 2}))
+	// Output: 2x^3 - x^2 + 1
+}
@@ -0,0 +1,268 @@
+package roots
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+	"sync"
+)
+
+// Lehmer's problem asks whether the Mahler measures of integer polynomials above 1 are
+// bounded away from 1. The measure is 1 exactly for products of cyclotomic polynomials and
+// x (Kronecker), and the smallest values known come from Salem numbers; Pisot numbers are
+// their counterpart with no conjugates on the unit circle. Classify sorts a polynomial into
+// these kinds: cyclotomic factors are divided out exactly, and the roots do the rest.
+
+// Kind is what Classify makes of a polynomial
+type Kind int
+
+const (
+	Ordinary     Kind = iota // None of the below
+	Cyclotomic               // Monic with every root 0 or a root of unity; Mahler measure 1
+	Pisot                    // Monic with one root θ > 1 and, cyclotomic factors apart, the others strictly inside the unit disk
+	Salem                    // Monic with one root θ > 1, its inverse and, cyclotomic factors apart, the others on the unit circle
+	SmallMeasure             // None of the above, with Mahler measure above 1 but below the bound
+)
+
+// kindNames are the names of the kinds, indexed by Kind
+var kindNames = []string{"ordinary", "cyclotomic", "pisot", "salem", "small-measure"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// Classification describes a polynomial for Lehmer's problem
+type Classification struct {
+	Kind    Kind
+	Measure float64 // Mahler measure; exactly 1 for Cyclotomic
+	Number  float64 // The root θ of a Pisot or Salem polynomial
+	Factor  []int   // What is left without x and the cyclotomic factors, constant term first; θ's minimal polynomial for Pisot and Salem
+	Orders  []int   // m of each cyclotomic factor Φ_m divided out, ascending and repeated with multiplicity
+}
+
+// UnitTolerance is how far from the unit circle a root is taken to lie on it. Roots of
+// unity of multiplicity up to 3 or so come out of Newton's method within it.
+const UnitTolerance = 1e-4
+
+// candidateTolerance is how near a root of unity a root must be to have its cyclotomic
+// polynomial tried as a factor. Multiple roots scatter further than UnitTolerance, and the
+// exact division rules out the false candidates.
+const candidateTolerance = 1e-2
+
+// Classify classifies the polynomial with integer coefficients coeffs, constant term first,
+// whose roots are zs; a measure below smallBound makes SmallMeasure
+func Classify(coeffs []int, zs []complex128, smallBound float64) Classification {
+	var c Classification
+	rest := coeffs
+	for len(rest) > 1 && rest[0] == 0 {
+		rest = rest[1:]
+	}
+	degree := len(rest) - 1
+
+	// Divide out the cyclotomic factors that the roots near the unit circle point to
+	var candidates []int
+	for _, z := range zs {
+		if math.Abs(cmplx.Abs(z)-1) < candidateTolerance {
+			for _, m := range unityOrders(z, degree) {
+				if !containsInt(candidates, m) {
+					candidates = append(candidates, m)
+				}
+			}
+		}
+	}
+	sort.Ints(candidates)
+	for _, m := range candidates {
+		for {
+			q, ok := dividePolynomial(rest, CyclotomicPolynomial(m))
+			if !ok {
+				break
+			}
+			rest = q
+			c.Orders = append(c.Orders, m)
+		}
+	}
+	c.Factor = append([]int(nil), rest...)
+
+	// The measure of what is left, from the roots not taken by a cyclotomic factor: each
+	// factor takes the root nearest each of its roots of unity
+	taken := make([]bool, len(zs))
+	for _, m := range c.Orders {
+		for _, zeta := range PrimitiveRoots(m) {
+			nearest := -1
+			for i, z := range zs {
+				if !taken[i] && (nearest < 0 || cmplx.Abs(z-zeta) < cmplx.Abs(zs[nearest]-zeta)) {
+					nearest = i
+				}
+			}
+			if nearest >= 0 {
+				taken[nearest] = true
+			}
+		}
+	}
+	lead := math.Abs(float64(rest[len(rest)-1]))
+	c.Measure = lead
+	outside := 0
+	var theta complex128
+	for i, z := range zs {
+		if taken[i] {
+			continue
+		}
+		if r := cmplx.Abs(z); r > 1+UnitTolerance {
+			c.Measure *= r
+			outside++
+			theta = z
+		}
+	}
+	monic := lead == 1
+	switch {
+	case monic && len(rest) == 1:
+		c.Kind, c.Measure = Cyclotomic, 1
+	case monic && outside == 1 && real(theta) > 1 && math.Abs(imag(theta)) < UnitTolerance:
+		// Anything else would be a second factor with every root in the closed disk, which is
+		// cyclotomic or x (Kronecker), so rest is θ's minimal polynomial. One on the unit
+		// circle makes it reciprocal, and a reciprocal one of degree 4 or more has some.
+		c.Number, c.Measure = real(theta), real(theta)
+		c.Kind = Pisot
+		if len(rest) >= 5 && isPalindromic(rest) {
+			c.Kind = Salem
+		}
+	case c.Measure > 1 && c.Measure < smallBound:
+		c.Kind = SmallMeasure
+	}
+	return c
+}
+
+// unityOrders returns the m for which z is within candidateTolerance of a primitive m-th
+// root of unity and Φ_m could divide a polynomial of the degree
+func unityOrders(z complex128, degree int) []int {
+	var found []int
+	turns := cmplx.Phase(z) / (2 * math.Pi)
+	for _, m := range cyclotomicOrders(degree) {
+		k := math.Round(turns * float64(m))
+		zeta := cmplx.Rect(1, 2*math.Pi*k/float64(m))
+		if cmplx.Abs(z-zeta) < candidateTolerance && gcd(int(math.Abs(k)), m) == 1 {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// isPalindromic says whether coefficients read the same forwards and backwards
+func isPalindromic(coeffs []int) bool {
+	for i, c := range coeffs {
+		if c != coeffs[len(coeffs)-1-i] {
+			return false
+		}
+	}
+	return true
+}
+
+// dividePolynomial divides p by the monic d, both constant term first, and says whether d
+// divides p exactly
+func dividePolynomial(p, d []int) ([]int, bool) {
+	n, k := len(p)-1, len(d)-1
+	if n < k {
+		return nil, false
+	}
+	rem := append([]int(nil), p...)
+	q := make([]int, n-k+1)
+	for i := n - k; i >= 0; i-- {
+		q[i] = rem[i+k]
+		for j, dj := range d {
+			rem[i+j] -= q[i] * dj
+		}
+	}
+	for _, r := range rem[:k] {
+		if r != 0 {
+			return nil, false
+		}
+	}
+	return q, true
+}
+
+var (
+	cyclotomicMu         sync.Mutex
+	cyclotomicCache      = map[int][]int{1: {-1, 1}}
+	cyclotomicOrderCache = map[int][]int{}
+)
+
+// PrimitiveRoots returns the primitive m-th roots of unity, the roots of Φ_m, by angle
+func PrimitiveRoots(m int) []complex128 {
+	var zs []complex128
+	for k := 0; k < m; k++ {
+		if gcd(k, m) == 1 {
+			zs = append(zs, cmplx.Rect(1, 2*math.Pi*float64(k)/float64(m)))
+		}
+	}
+	return zs
+}
+
+// CyclotomicPolynomial returns Φ_m, the minimal polynomial of the primitive m-th roots of
+// unity, constant term first
+func CyclotomicPolynomial(m int) []int {
+	cyclotomicMu.Lock()
+	phi, ok := cyclotomicCache[m]
+	cyclotomicMu.Unlock()
+	if ok {
+		return phi
+	}
+	// x^m - 1 is the product of Φ_d over the divisors d of m
+	phi = make([]int, m+1)
+	phi[0], phi[m] = -1, 1
+	for d := 1; d < m; d++ {
+		if m%d == 0 {
+			phi, _ = dividePolynomial(phi, CyclotomicPolynomial(d))
+		}
+	}
+	cyclotomicMu.Lock()
+	cyclotomicCache[m] = phi
+	cyclotomicMu.Unlock()
+	return phi
+}
+
+// cyclotomicOrders returns the m with φ(m) <= degree in ascending order. Since
+// φ(m) >= sqrt(m/2), they are below 2 degree^2.
+func cyclotomicOrders(degree int) []int {
+	cyclotomicMu.Lock()
+	defer cyclotomicMu.Unlock()
+	orders, ok := cyclotomicOrderCache[degree]
+	if !ok {
+		for m := 1; m <= 2*degree*degree; m++ {
+			if totient(m) <= degree {
+				orders = append(orders, m)
+			}
+		}
+		cyclotomicOrderCache[degree] = orders
+	}
+	return orders
+}
+
+// totient is Euler's φ(m), the degree of Φ_m
+func totient(m int) int {
+	count := 0
+	for k := 1; k <= m; k++ {
+		if gcd(k, m) == 1 {
+			count++
+		}
+	}
+	return count
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+// Package roots finds the complex roots of integer polynomials by Newton's method with
+// deflation, computes the invariants the renderer colors them by, and classifies them for
+// Lehmer's problem.
+package roots
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Newton's method parameters, recorded in the output metadata. Tolerance is relative: a
+// root is taken once the step is below Tolerance times its size (or 1 near zero), or the
+// polynomial's value there below Tolerance times the size of its terms.
+const (
+	MaxIterations = 5000
+	Tolerance     = 1e-14
+)
+
+// Find implements Newton's method for polynomial root finding with custom random source
+func Find(coeffs []complex128, order int, rng *rand.Rand) []complex128 {
+	if order == 1 {
+		if coeffs[1] != 0 {
+			return []complex128{-coeffs[0] / coeffs[1]}
+		}
+		return nil
+	}
+
+	// Factor out x exactly; Newton's method crawls towards a multiple root at zero
+	if coeffs[0] == 0 {
+		return append([]complex128{0}, Find(coeffs[1:], order-1, rng)...)
+	}
+
+	var roots []complex128
+	const maxIters = MaxIterations
+	const tolerance = Tolerance
+
+	// Start with a random initial guess
+	root := complex(rng.Float64()*2-1, rng.Float64()*2-1)
+
+	for iter := 0; iter < maxIters; iter++ {
+		oldRoot := root
+
+		// Compute f(root) and f'(root) using Horner's method, and the size of the terms of
+		// f(root), which bounds its rounding error
+		f := coeffs[order]
+		df := complex(0, 0)
+		size := cmplx.Abs(f)
+		r := cmplx.Abs(root)
+
+		for i := order - 1; i >= 0; i-- {
+			df = df*root + f
+			f = f*root + coeffs[i]
+			size = size*r + cmplx.Abs(coeffs[i])
+		}
+
+		// Near a multiple root f' vanishes and the steps stall; take the root once f(root)
+		// is lost in rounding
+		if cmplx.Abs(f) <= tolerance*size {
+			roots = append(roots, root)
+			break
+		}
+
+		if cmplx.Abs(df) < 1e-15 {
+			// Derivative too small, try new starting point
+			root = complex(rng.Float64()*2-1, rng.Float64()*2-1)
+			continue
+		}
+
+		// Newton's method step
+		root = root - f/df
+
+		// Check convergence
+		if cmplx.Abs(root-oldRoot) <= tolerance*math.Max(1, cmplx.Abs(root)) {
+			roots = append(roots, root)
+			break
+		}
+
+		// Restart with new random point occasionally
+		if iter%500 == 0 && iter > 0 {
+			root = complex(rng.Float64()*2-1, rng.Float64()*2-1)
+		}
+	}
+
+	// Deflate polynomial and find remaining roots
+	if len(roots) > 0 {
+		r := roots[0]
+		// Synthetic division: reduce polynomial by factor (x - r)
+		newCoeffs := make([]complex128, order)
+		newCoeffs[order-1] = coeffs[order]
+		for i := order - 2; i >= 0; i-- {
+			newCoeffs[i] = coeffs[i+1] + r*newCoeffs[i+1]
+		}
+
+		// Find remaining roots
+		remaining := Find(newCoeffs, order-1, rng)
+		roots = append(roots, remaining...)
+	}
+
+	return roots
+}
+
+// Invariants computes |discriminant| and Mahler measure of a polynomial from its roots
+func Invariants(roots []complex128, leadingCoeff int) (disc, mahler float64) {
+	lead := math.Abs(float64(leadingCoeff))
+	n := len(roots)
+
+	// |disc| = |a_n|^(2n-2) * prod_{i<j} |r_i - r_j|^2
+	disc = math.Pow(lead, float64(2*n-2))
+	mahler = lead
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := cmplx.Abs(roots[i] - roots[j])
+			disc *= d * d
+		}
+		mahler *= math.Max(1, cmplx.Abs(roots[i]))
+	}
+	return disc, mahler
+}
+
+// Multiplicity counts how many of the roots coincide with roots[idx]
+// Newton's method only resolves a root of multiplicity m to about 1e-16^(1/m), hence the loose tolerance
+func Multiplicity(roots []complex128, idx int) int {
+	const tolerance = 1e-4
+	mult := 0
+	for _, r := range roots {
+		if cmplx.Abs(r-roots[idx]) < tolerance*math.Max(1, cmplx.Abs(r)) {
+			mult++
+		}
+	}
+	return mult
+}
+
+// FormatPolynomial writes integer coefficients (constant term first) as e.g. "2x^3 - x + 1"
+func FormatPolynomial(coeffs []int) string {
+	var b strings.Builder
+	for j := len(coeffs) - 1; j >= 0; j-- {
+		c := coeffs[j]
+		if c == 0 {
+			continue
+		}
+		switch {
+		case b.Len() == 0 && c < 0:
+			b.WriteString("-")
+		case b.Len() > 0 && c < 0:
+			b.WriteString(" - ")
+		case b.Len() > 0:
+			b.WriteString(" + ")
+		}
+		if c < 0 {
+			c = -c
+		}
+		if c != 1 || j == 0 {
+			b.WriteString(strconv.Itoa(c))
+		}
+		if j >= 1 {
+			b.WriteString("x")
+		}
+		if j >= 2 {
+			b.WriteString("^" + strconv.Itoa(j))
+		}
+	}
+	if b.Len() == 0 {
+		return "0"
+	}
+	return b.String()
+}
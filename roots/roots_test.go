@@ -0,0 +1,283 @@
+package roots
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// intPoly is a random integer polynomial for quick.Check, constant term first, with a
+// nonzero leading coefficient
+type intPoly []int
+
+// Generate makes polynomials of degree 1 to 20 with coefficients in [-10, 10]
+func (intPoly) Generate(r *rand.Rand, _ int) reflect.Value {
+	p := make(intPoly, 2+r.Intn(20))
+	for i := range p {
+		p[i] = r.Intn(21) - 10
+	}
+	for p[len(p)-1] == 0 {
+		p[len(p)-1] = r.Intn(21) - 10
+	}
+	return reflect.ValueOf(p)
+}
+
+func (p intPoly) complex() []complex128 {
+	c := make([]complex128, len(p))
+	for i, v := range p {
+		c[i] = complex(float64(v), 0)
+	}
+	return c
+}
+
+// residual returns |p(z)| relative to the size of the terms of p(z), which bounds the
+// rounding error of evaluating it
+func (p intPoly) residual(z complex128) float64 {
+	f := complex(float64(p[len(p)-1]), 0)
+	size := math.Abs(float64(p[len(p)-1]))
+	for i := len(p) - 2; i >= 0; i-- {
+		f = f*z + complex(float64(p[i]), 0)
+		size = size*cmplx.Abs(z) + math.Abs(float64(p[i]))
+	}
+	return cmplx.Abs(f) / size
+}
+
+// maxResidual bounds p(z) at a root z, relative to the size of its terms. Deflation passes
+// the error of each root on to the next, so roots found late hold less than full precision.
+const maxResidual = 1e-6
+
+var quickConfig = &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(1))}
+
+func TestFindReturnsAllRoots(t *testing.T) {
+	prop := func(p intPoly, seed int64) bool {
+		degree := len(p) - 1
+		zs := Find(p.complex(), degree, rand.New(rand.NewSource(seed)))
+		if len(zs) != degree {
+			t.Logf("%s: %d roots, want %d", FormatPolynomial(p), len(zs), degree)
+			return false
+		}
+		for _, z := range zs {
+			if r := p.residual(z); r > maxResidual {
+				t.Logf("%s: residual %g at %v", FormatPolynomial(p), r, z)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFindMultipleRoots(t *testing.T) {
+	tests := []struct {
+		coeffs []int
+		want   []complex128 // With multiplicity
+	}{
+		{[]int{0, 0, 0, 1}, []complex128{0, 0, 0}},                      // x^3
+		{[]int{0, 0, 2, 1}, []complex128{0, 0, -2}},                     // x^3 + 2x^2
+		{[]int{1, -2, 1}, []complex128{1, 1}},                           // (x - 1)^2
+		{[]int{1, 0, 2, 0, 1}, []complex128{1i, 1i, -1i, -1i}},          // (x^2 + 1)^2
+		{[]int{-1, 3, -3, 1}, []complex128{1, 1, 1}},                    // (x - 1)^3
+		{[]int{4, 4, -3, -2, 1}, []complex128{2, 2, -1, -1}},            // (x - 2)^2 (x + 1)^2
+		{[]int{1, 0, 0, 0, 0, 0, 0, 0, 1}, nil},                         // x^8 + 1, checked by residual only
+		{[]int{-1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, nil},            // x^12 - 1
+		{[]int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, nil}, // Cyclotomic, degree 16
+	}
+	for _, tt := range tests {
+		p := intPoly(tt.coeffs)
+		degree := len(p) - 1
+		zs := Find(p.complex(), degree, rand.New(rand.NewSource(1)))
+		if len(zs) != degree {
+			t.Errorf("%s: %d roots %v, want %d", FormatPolynomial(p), len(zs), zs, degree)
+			continue
+		}
+		for _, z := range zs {
+			if r := p.residual(z); r > maxResidual {
+				t.Errorf("%s: residual %g at %v", FormatPolynomial(p), r, z)
+			}
+		}
+		if tt.want == nil {
+			continue
+		}
+		// Newton resolves a root of multiplicity m to about 1e-16^(1/m)
+		for i, z := range zs {
+			want := 0
+			for _, w := range tt.want {
+				if cmplx.Abs(z-w) < 1e-4 {
+					want++
+				}
+			}
+			if m := Multiplicity(zs, i); m != want {
+				t.Errorf("%s: multiplicity %d at %v, want %d", FormatPolynomial(p), m, z, want)
+			}
+		}
+	}
+}
+
+func TestFindIsDeterministic(t *testing.T) {
+	prop := func(p intPoly, seed int64) bool {
+		a := Find(p.complex(), len(p)-1, rand.New(rand.NewSource(seed)))
+		b := Find(p.complex(), len(p)-1, rand.New(rand.NewSource(seed)))
+		return reflect.DeepEqual(a, b)
+	}
+	if err := quick.Check(prop, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvariants(t *testing.T) {
+	tests := []struct {
+		coeffs       []int
+		disc, mahler float64
+	}{
+		{[]int{-1, -1, 1}, 5, (1 + math.Sqrt(5)) / 2}, // x^2 - x - 1
+		{[]int{1, 0, 1}, 4, 1},                        // x^2 + 1
+		{[]int{-2, 0, 3}, 24, 3},                      // 3x^2 - 2: disc = 0 - 4*3*(-2)
+		{[]int{-1, -1, 0, 1}, 23, 1.324717957244746},  // x^3 - x - 1, the plastic number
+		{[]int{1, -2, 1}, 0, 1},                       // (x - 1)^2
+	}
+	for _, tt := range tests {
+		p := intPoly(tt.coeffs)
+		zs := Find(p.complex(), len(p)-1, rand.New(rand.NewSource(1)))
+		disc, mahler := Invariants(zs, p[len(p)-1])
+		if math.Abs(disc-tt.disc) > 1e-6*math.Max(1, tt.disc) {
+			t.Errorf("%s: |discriminant| %g, want %g", FormatPolynomial(p), disc, tt.disc)
+		}
+		if math.Abs(mahler-tt.mahler) > 1e-6*tt.mahler { // A double root is only good to 1e-8
+			t.Errorf("%s: Mahler measure %.15g, want %.15g", FormatPolynomial(p), mahler, tt.mahler)
+		}
+	}
+}
+
+func TestCyclotomicPolynomial(t *testing.T) {
+	tests := []struct {
+		m    int
+		want []int
+	}{
+		{1, []int{-1, 1}},
+		{2, []int{1, 1}},
+		{4, []int{1, 0, 1}},
+		{6, []int{1, -1, 1}},
+		{12, []int{1, 0, -1, 0, 1}},
+		{15, []int{1, -1, 0, 1, -1, 1, 0, -1, 1}},
+	}
+	for _, tt := range tests {
+		if got := CyclotomicPolynomial(tt.m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Φ_%d = %s, want %s", tt.m, FormatPolynomial(got), FormatPolynomial(tt.want))
+		}
+	}
+	// The first with a coefficient other than 0 and ±1
+	if got := CyclotomicPolynomial(105); got[7] != -2 || len(got) != 49 {
+		t.Errorf("Φ_105 = %s", FormatPolynomial(got))
+	}
+}
+
+func TestPrimitiveRoots(t *testing.T) {
+	// Φ_m has a root for each k < m prime to m, and each is a root of x^m - 1 but of no
+	// lower power
+	for m := 1; m <= 30; m++ {
+		zs := PrimitiveRoots(m)
+		if len(zs) != len(CyclotomicPolynomial(m))-1 {
+			t.Errorf("m = %d: %d roots, want the degree of Φ_m, %d", m, len(zs), len(CyclotomicPolynomial(m))-1)
+		}
+		for _, z := range zs {
+			for d := 1; d < m; d++ {
+				if cmplx.Abs(cmplx.Pow(z, complex(float64(d), 0))-1) < 1e-9 {
+					t.Errorf("m = %d: %v is a root of x^%d - 1", m, z, d)
+				}
+			}
+			if cmplx.Abs(cmplx.Pow(z, complex(float64(m), 0))-1) > 1e-9 {
+				t.Errorf("m = %d: %v is not a root of x^%d - 1", m, z, m)
+			}
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	lehmer := []int{1, 1, 0, -1, -1, -1, -1, -1, 0, 1, 1} // x^10 + x^9 - x^7 - ... + x + 1
+	lehmerNeg := make([]int, len(lehmer))                 // Its roots negated
+	for i, c := range lehmer {
+		lehmerNeg[i] = c * (1 - 2*(i%2))
+	}
+	tests := []struct {
+		coeffs  []int
+		kind    Kind
+		measure float64
+		orders  []int
+	}{
+		{[]int{-1, -1, 1}, Pisot, (1 + math.Sqrt(5)) / 2, nil},                    // x^2 - x - 1, the golden ratio
+		{[]int{-1, -1, 0, 1}, Pisot, 1.324717957244746, nil},                      // x^3 - x - 1, the plastic number
+		{[]int{1, -3, 1}, Pisot, (3 + math.Sqrt(5)) / 2, nil},                     // Reciprocal but quadratic
+		{[]int{-1, -2, 0, 1}, Pisot, (1 + math.Sqrt(5)) / 2, []int{2}},            // (x^2 - x - 1)(x + 1)
+		{[]int{0, -3, 1}, Pisot, 3, nil},                                          // x(x - 3)
+		{lehmer, Salem, 1.176280818259918, nil},                                   // Lehmer's number
+		{[]int{1, -1, -1, -1, 1}, Salem, 1.722083805739043, nil},                  // The smallest quartic Salem number
+		{[]int{1, 1, 1, 1, 1}, Cyclotomic, 1, []int{5}},                           // Φ_5
+		{[]int{0, 1, -2, 1}, Cyclotomic, 1, []int{1, 1}},                          // x(x - 1)^2
+		{[]int{-1, 1, -1, 1}, Cyclotomic, 1, []int{1, 4}},                         // (x - 1)(x^2 + 1)
+		{[]int{1, -2, 0, 1, 1, 0, -2, 1}, Cyclotomic, 1, []int{1, 1, 1, 1, 2, 3}}, // (x - 1)^4 (x + 1)(x^2 + x + 1), scattered roots
+		{lehmerNeg, SmallMeasure, 1.176280818259918, nil},                         // Its root outside the disk is negative
+		{[]int{1, 0, 2}, Ordinary, 2, nil},                                        // 2x^2 + 1
+		{[]int{2, 1}, Ordinary, 2, nil},                                           // x + 2
+	}
+	for _, tt := range tests {
+		p := intPoly(tt.coeffs)
+		zs := Find(p.complex(), len(p)-1, rand.New(rand.NewSource(1)))
+		c := Classify(p, zs, 1.3)
+		if c.Kind != tt.kind || math.Abs(c.Measure-tt.measure) > 1e-9*tt.measure || !reflect.DeepEqual(c.Orders, tt.orders) {
+			t.Errorf("%s: %s with measure %.15g and cyclotomic factors %v, want %s with %.15g and %v",
+				FormatPolynomial(p), c.Kind, c.Measure, c.Orders, tt.kind, tt.measure, tt.orders)
+		}
+		if (c.Kind == Pisot || c.Kind == Salem) && c.Number != c.Measure {
+			t.Errorf("%s: number %g, measure %g", FormatPolynomial(p), c.Number, c.Measure)
+		}
+	}
+}
+
+func TestFormatPolynomial(t *testing.T) {
+	tests := []struct {
+		coeffs []int
+		want   string
+	}{
+		{[]int{0}, "0"},
+		{[]int{5}, "5"},
+		{[]int{0, 1}, "x"},
+		{[]int{0, -1}, "-x"},
+		{[]int{-1, 0, 1}, "x^2 - 1"},
+		{[]int{1, -1, 0, -2}, "-2x^3 - x + 1"},
+		{[]int{3, 2, 1}, "x^2 + 2x + 3"},
+	}
+	for _, tt := range tests {
+		if got := FormatPolynomial(tt.coeffs); got != tt.want {
+			t.Errorf("FormatPolynomial(%v) = %q, want %q", tt.coeffs, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	for _, degree := range []int{2, 5, 10, 15, 20} {
+		b.Run(fmt.Sprintf("degree-%d", degree), func(b *testing.B) {
+			// A fixed set of polynomials, so that runs compare
+			gen := rand.New(rand.NewSource(int64(degree)))
+			polys := make([][]complex128, 64)
+			for i := range polys {
+				p := make(intPoly, degree+1)
+				for j := range p {
+					p[j] = gen.Intn(21) - 10
+				}
+				p[degree] = 1 + gen.Intn(10)
+				polys[i] = p.complex()
+			}
+			rng := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Find(polys[i%len(polys)], degree, rng)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"log/slog"
+	"math/cmplx"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/render"
+	"github.com/jayalane/algebraic_vis/roots"
+)
+
+//go:embed viewer.html
+var viewerHTML []byte
+
+const (
+	serveMaxZoom   = 24  // Deepest zoom the tile endpoint renders
+	serveIndexGrid = 512 // Cells per side of the point index
+	maxBlobReach   = 85  // Largest blob radius plus the margin drawn around it, in pixels
+)
+
+// pointIndex buckets the points inside a viewport into a square grid for range queries
+type pointIndex struct {
+	points []enumerate.Point
+	config render.Config // Viewport covered by the grid
+	cells  [][]int32
+}
+
+// newPointIndex indexes the points inside config's viewport
+func newPointIndex(points []enumerate.Point, config render.Config) *pointIndex {
+	ix := &pointIndex{points: points, config: config, cells: make([][]int32, serveIndexGrid*serveIndexGrid)}
+	for i, p := range points {
+		x, y := real(p.Z), imag(p.Z)
+		if x < config.XMin || x > config.XMax || y < config.YMin || y > config.YMax {
+			continue
+		}
+		col, row := ix.cell(x, y)
+		ix.cells[row*serveIndexGrid+col] = append(ix.cells[row*serveIndexGrid+col], int32(i))
+	}
+	return ix
+}
+
+// cell returns the grid cell containing (x, y), clamped to the grid
+func (ix *pointIndex) cell(x, y float64) (int, int) {
+	c := ix.config
+	col := int((x - c.XMin) / (c.XMax - c.XMin) * serveIndexGrid)
+	row := int((y - c.YMin) / (c.YMax - c.YMin) * serveIndexGrid)
+	return min(max(col, 0), serveIndexGrid-1), min(max(row, 0), serveIndexGrid-1)
+}
+
+// within returns the indexed points in the rectangle [xMin, xMax] x [yMin, yMax]
+func (ix *pointIndex) within(xMin, yMin, xMax, yMax float64) []enumerate.Point {
+	c0, r0 := ix.cell(xMin, yMin)
+	c1, r1 := ix.cell(xMax, yMax)
+	var found []enumerate.Point
+	for row := r0; row <= r1; row++ {
+		for col := c0; col <= c1; col++ {
+			for _, i := range ix.cells[row*serveIndexGrid+col] {
+				p := ix.points[i]
+				if x, y := real(p.Z), imag(p.Z); x >= xMin && x <= xMax && y >= yMin && y <= yMax {
+					found = append(found, p)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// tileServer renders XYZ tiles of a cached point set on demand
+type tileServer struct {
+	config render.Config // Square viewport covered by zoom 0, and the default coloring
+	points []enumerate.Point
+	index  *pointIndex
+
+	mu       sync.Mutex
+	colorers map[string]render.Colorer // By color scheme; data-range rules scan every point, so build each once
+}
+
+// newTileServer indexes points for serving tiles of config's viewport
+func newTileServer(points []enumerate.Point, config render.Config) *tileServer {
+	return &tileServer{
+		config:   config,
+		points:   points,
+		index:    newPointIndex(points, config),
+		colorers: make(map[string]render.Colorer),
+	}
+}
+
+// colorer returns the cached Colorer for a scheme
+func (s *tileServer) colorer(scheme string) render.Colorer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.colorers[scheme]
+	if !ok {
+		c = render.NewColorer(scheme, s.points, s.config)
+		s.colorers[scheme] = c
+	}
+	return c
+}
+
+// handler routes the viewer, tile and API endpoints
+func (s *tileServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleViewer)
+	mux.HandleFunc("/tiles/", s.handleTile)
+	mux.HandleFunc("/api/info", s.handleInfo)
+	mux.HandleFunc("/api/roots", s.handleRoots)
+	return mux
+}
+
+func (s *tileServer) handleViewer(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(viewerHTML)
+}
+
+// handleTile serves /tiles/{z}/{x}/{y}.png, with an optional ?color= scheme
+func (s *tileServer) handleTile(w http.ResponseWriter, r *http.Request) {
+	var z, x, y int
+	if n, _ := fmt.Sscanf(r.URL.Path, "/tiles/%d/%d/%d.png", &z, &x, &y); n != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if z < 0 || z > serveMaxZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		http.NotFound(w, r)
+		return
+	}
+	scheme := r.URL.Query().Get("color")
+	if scheme == "" {
+		scheme = s.config.ColorBy
+	}
+	if _, ok := render.ColorSchemes[scheme]; !ok {
+		http.Error(w, fmt.Sprintf("unknown color scheme %q", scheme), http.StatusBadRequest)
+		return
+	}
+
+	level := render.PyramidLevel(s.config, z)
+	level.ColorBy = scheme
+	rect := image.Rect(x*render.PyramidTileSize, y*render.PyramidTileSize, (x+1)*render.PyramidTileSize, (y+1)*render.PyramidTileSize)
+
+	// The tile's patch of the plane, widened so blobs centered just outside still bleed in
+	unit := (level.XMax - level.XMin) / float64(level.Width)
+	xMin := level.XMin + float64(rect.Min.X-maxBlobReach)*unit
+	xMax := level.XMin + float64(rect.Max.X+maxBlobReach)*unit
+	yMax := level.YMax - float64(rect.Min.Y-maxBlobReach)*unit
+	yMin := level.YMax - float64(rect.Max.Y+maxBlobReach)*unit
+
+	img := image.NewRGBA(rect)
+	render.DrawPoints(img, s.index.within(xMin, yMin, xMax, yMax), s.colorer(scheme), level)
+	if level.Overlays.UnitCircle {
+		render.DrawUnitCircle(img, level)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	if err := render.EncodePNGWithChunks(w, img, render.OutputChunks(level)); err != nil {
+		slog.Warn("Failed to encode tile: "+err.Error(), "tile", fmt.Sprintf("%d/%d/%d", z, x, y))
+	}
+}
+
+// serveInfo describes the served plane to the viewer
+type serveInfo struct {
+	XMin         float64  `json:"xMin"`
+	YMin         float64  `json:"yMin"`
+	XMax         float64  `json:"xMax"`
+	YMax         float64  `json:"yMax"`
+	TileSize     int      `json:"tileSize"`
+	MaxZoom      int      `json:"maxZoom"`
+	MaxHeight    int      `json:"maxHeight"`
+	Points       int      `json:"points"`
+	ColorBy      string   `json:"colorBy"`
+	ColorSchemes []string `json:"colorSchemes"`
+}
+
+func (s *tileServer) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, serveInfo{
+		XMin: s.config.XMin, YMin: s.config.YMin, XMax: s.config.XMax, YMax: s.config.YMax,
+		TileSize: render.PyramidTileSize, MaxZoom: serveMaxZoom, MaxHeight: s.config.MaxHeight,
+		Points: len(s.points), ColorBy: s.config.ColorBy, ColorSchemes: render.ColorSchemeNames(),
+	})
+}
+
+// rootInfo is one algebraic number returned by /api/roots
+type rootInfo struct {
+	Re           float64 `json:"re"`
+	Im           float64 `json:"im"`
+	Distance     float64 `json:"distance"`
+	Polynomial   string  `json:"polynomial"`
+	Coeffs       []int   `json:"coeffs"` // Constant term first
+	Height       int     `json:"height"`
+	Degree       int     `json:"degree"`
+	LeadingCoeff int     `json:"leadingCoeff"`
+	Multiplicity int     `json:"multiplicity"`
+	Discriminant float64 `json:"discriminant"`
+	Mahler       float64 `json:"mahler"`
+}
+
+// handleRoots serves /api/roots?re=X&im=Y&r=R[&limit=N]: the roots within R of X+Yi, nearest first
+func (s *tileServer) handleRoots(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	re, err1 := strconv.ParseFloat(q.Get("re"), 64)
+	im, err2 := strconv.ParseFloat(q.Get("im"), 64)
+	radius, err3 := strconv.ParseFloat(q.Get("r"), 64)
+	if err1 != nil || err2 != nil || err3 != nil || radius <= 0 {
+		http.Error(w, "want re, im and a positive r", http.StatusBadRequest)
+		return
+	}
+	limit := 20
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, 1000)
+	}
+
+	center := complex(re, im)
+	found := []rootInfo{}
+	for _, p := range s.index.within(re-radius, im-radius, re+radius, im+radius) {
+		d := cmplx.Abs(p.Z - center)
+		if d > radius {
+			continue
+		}
+		found = append(found, rootInfo{
+			Re: real(p.Z), Im: imag(p.Z), Distance: d,
+			Polynomial: roots.FormatPolynomial(p.Coeffs), Coeffs: p.Coeffs,
+			Height: p.H, Degree: p.O, LeadingCoeff: p.LeadingCoeff, Multiplicity: p.Mult,
+			Discriminant: p.Disc, Mahler: p.Mahler,
+		})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Distance < found[j].Distance })
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	writeJSON(w, found)
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write response: " + err.Error())
+	}
+}
+
+func printServeUsage(progName string) {
+	fmt.Printf("Usage: %s serve [flags] [x_min y_min x_max y_max]\n", progName)
+	fmt.Printf("  Serves a pan-zoom viewer of the rectangle, rendering tiles on demand.\n")
+	fmt.Printf("  A non-square rectangle is widened about its center to a square.\n")
+	fmt.Printf("\nFlags:\n")
+	fmt.Printf("  --addr ADDR       Listen address (default: localhost:8080)\n")
+	fmt.Printf("  --max-height N    Maximum polynomial height (default: 12)\n")
+	fmt.Printf("  --family NAME     Polynomials to enumerate: %s (default: all)\n", enumerate.FamilyList)
+	fmt.Printf("  --color-by RULE   Initial coloring rule: %s (default: leading)\n", render.ColorSchemeList())
+	fmt.Printf("  --palette NAME    Palette: %s, or a .json/.gpl file\n", render.PaletteList())
+	fmt.Printf("  --unit-circle     Draw the circle |z| = 1\n")
+	printProgressUsage()
+	printResourceUsage()
+	printLogUsage()
+	printProfileUsage()
+	fmt.Printf("\nEndpoints:\n")
+	fmt.Printf("  /                            The viewer; click a blob to list the roots under it\n")
+	fmt.Printf("  /tiles/{z}/{x}/{y}.png       XYZ tiles, with an optional ?color=RULE\n")
+	fmt.Printf("  /api/roots?re=X&im=Y&r=R     Roots within R of X+Yi and their polynomials, as JSON\n")
+	fmt.Printf("  /api/info                    Viewport and settings, as JSON\n")
+	fmt.Printf("\nExamples:\n")
+	fmt.Printf("  %s serve --addr :8080 --max-height 14\n", progName)
+	fmt.Printf("  %s serve -- -1 -1 1 1\n", progName)
+}
+
+// runServe implements the serve subcommand
+func runServe(progName string, args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Listen address")
+	maxHeight := fs.Int("max-height", 12, "Maximum polynomial height")
+	familyName := fs.String("family", "all", "Polynomials to enumerate: "+enumerate.FamilyList)
+	colorBy := fs.String("color-by", "leading", "Initial coloring rule: "+render.ColorSchemeList())
+	paletteSpec := fs.String("palette", "", "Palette: "+render.PaletteList()+", or a .json/.gpl file")
+	unitCircle := fs.Bool("unit-circle", false, "Draw the circle |z| = 1")
+	progress := fs.String("progress", "text", "Enumeration progress on stderr: "+progressModes)
+	rs := addResourceFlags(fs)
+	lf := addLogFlags(fs)
+	pf := addProfileFlags(fs)
+	fs.Usage = func() {
+		printServeUsage(progName)
+	}
+	fs.Parse(args)
+	if err := lf.setup(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if err := checkProgressMode(*progress); err != nil {
+		fatalf("%v", err)
+	}
+	family, err := enumerate.ParseFamily(*familyName)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	config := squareConfig(fs.Args(), *maxHeight, *colorBy, *paletteSpec, *unitCircle, fs.Usage)
+	if err := rs.apply(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := pf.start(); err != nil {
+		fatalf("%v", err)
+	}
+	defer stopProfiling()
+
+	// Ctrl-C during the enumeration serves what finished; Ctrl-C while serving shuts down
+	ctx, stop := interruptContext()
+	defer stop()
+	slog.Info("Calculating algebraic numbers")
+	opts := enumerate.Options{Progress: progressReporter(*progress), Workers: *rs.workers, Family: family, Logger: slog.Default()}
+	points, _, err := enumerate.Run(ctx, config.MaxHeight, 0, opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	s := newTileServer(points, config)
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if ctx.Err() == nil {
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+	}
+	slog.Info("Serving viewer at http://" + displayAddr(*addr) + "/")
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fatalf("%v", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// displayAddr turns a listen address like ":8080" into something a browser can open
+func displayAddr(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
+	}
+	return addr
+}
@@ -0,0 +1,27 @@
+package video_test
+
+import (
+	"context"
+	"log"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/render"
+	"github.com/jayalane/algebraic_vis/video"
+)
+
+func ExampleGenerate() {
+	// Needs ffmpeg on the PATH
+	points, enum := enumerate.Generate(context.Background(), 12, 42)
+	config := render.Config{
+		Width: 1200, Height: 800,
+		XMin: -3, YMin: -2, XMax: 3, YMax: 2,
+		MaxHeight:  enum.MaxHeight,
+		OutputFile: "heights.mp4",
+		FrameRate:  2,
+		ColorBy:    "leading",
+		Seed:       enum.Seed,
+	}
+	if err := video.Generate(context.Background(), points, config); err != nil {
+		log.Fatal(err)
+	}
+}
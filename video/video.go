@@ -0,0 +1,175 @@
+// Package video animates a rendering height by height, encoding the frames with ffmpeg.
+package video
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayalane/algebraic_vis/enumerate"
+	"github.com/jayalane/algebraic_vis/internal/logging"
+	"github.com/jayalane/algebraic_vis/render"
+)
+
+// Generate creates an animation showing algebraic numbers filling in as height increases.
+// When ctx is cancelled no more frames are drawn, and the video is made from those already done.
+func Generate(ctx context.Context, points []enumerate.Point, config render.Config) error {
+	log := logging.Or(config.Logger)
+	// Create temporary directory for frames
+	tempDir, err := os.MkdirTemp("", "algebraic_frames")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir) // Clean up
+
+	log.Info("Generating video frames", "max_height", config.MaxHeight)
+
+	// Each frame shows a prefix of the points ordered by height
+	sorted := enumerate.SortByHeight(points)
+	frameNum := 0
+
+	// Heights past an interrupted enumeration would only repeat its last, partial frame
+	lastHeight := config.MaxHeight
+	if config.Run != nil && config.Run.StoppedAt > 0 {
+		lastHeight = config.Run.StoppedAt
+	}
+
+	for h := 2; h <= lastHeight; h++ {
+		if ctx.Err() != nil {
+			if config.Run != nil && (config.Run.StoppedAt == 0 || h < config.Run.StoppedAt) {
+				config.Run.StoppedAt = h
+			}
+			log.Warn("Stopped before the frame for height "+strconv.Itoa(h), "height", h)
+			break
+		}
+		log.Info("Generating frame", "height", h, "max_height", config.MaxHeight)
+
+		// For video, we want to show the cumulative effect
+		// So we keep all points from previous heights
+		framePoints := enumerate.UpToHeight(sorted, h)
+
+		// Render frame, marking only what its heights have found
+		frameConfig := config
+		frameConfig.Marks = enumerate.MarksUpTo(config.Marks, h)
+		img := render.Image(framePoints, frameConfig)
+
+		// Add height indicator text overlay
+		addTextOverlay(img, fmt.Sprintf("Height: %d", h), config)
+
+		// Save frame as JPEG (faster than PNG for video)
+		framePath := filepath.Join(tempDir, fmt.Sprintf("frame_%04d.jpg", frameNum))
+		if err := saveJPEG(img, framePath); err != nil {
+			return fmt.Errorf("failed to save frame %d: %v", frameNum, err)
+		}
+
+		frameNum++
+
+		// Add pause frames at interesting heights
+		if h <= 5 || h%5 == 0 {
+			// Hold this frame for a bit longer
+			for pause := 0; pause < config.FrameRate/2; pause++ {
+				framePath := filepath.Join(tempDir, fmt.Sprintf("frame_%04d.jpg", frameNum))
+				if err := saveJPEG(img, framePath); err != nil {
+					return fmt.Errorf("failed to save pause frame %d: %v", frameNum, err)
+				}
+				frameNum++
+			}
+		}
+	}
+
+	if frameNum == 0 {
+		return fmt.Errorf("interrupted before the first frame")
+	}
+
+	// Generate video using ffmpeg
+	// After an interrupt the frames drawn so far are still encoded
+	if ctx.Err() != nil {
+		ctx = context.WithoutCancel(ctx)
+	}
+	return createVideoFromFrames(ctx, log, tempDir, config.OutputFile, config.FrameRate, render.OutputMetadata(config))
+}
+
+// saveJPEG saves an image as JPEG
+func saveJPEG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return jpeg.Encode(file, img, &jpeg.Options{Quality: 90})
+}
+
+// addTextOverlay draws the video height indicator in the bottom-right corner
+func addTextOverlay(img *image.RGBA, text string, config render.Config) {
+	render.DrawLabel(img, text, render.AnchorBottomRight, config)
+}
+
+// createVideoFromFrames uses ffmpeg to create video from frame sequence.
+// Cancelling ctx interrupts ffmpeg, which finishes the video with the frames encoded so far.
+func createVideoFromFrames(ctx context.Context, log *slog.Logger, frameDir, outputFile string, frameRate int, metadata []render.TextChunk) error {
+	log.Info("Creating video from frames")
+
+	// Check if ffmpeg is available
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found. Please install ffmpeg to generate videos.\nOn Ubuntu/Debian: sudo apt install ffmpeg\nOn macOS: brew install ffmpeg")
+	}
+
+	// ffmpeg command to create video
+	args := []string{
+		"-y", // Overwrite output file
+		"-framerate", strconv.Itoa(frameRate),
+		"-i", filepath.Join(frameDir, "frame_%04d.jpg"),
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-crf", "18", // High quality
+	}
+	// Record the render settings in the container's comment tag
+	var comment []string
+	for _, t := range metadata {
+		comment = append(comment, t.Key+"="+t.Value)
+	}
+	args = append(args, "-metadata", "comment="+strings.Join(comment, "; "), outputFile)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 30 * time.Second // Then ffmpeg is killed
+
+	// Capture output
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %v", err)
+	}
+	stderr := bufio.NewScanner(stderrPipe)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %v", err)
+	}
+
+	// Pass ffmpeg's output on at debug level
+	go func() {
+		for stderr.Scan() {
+			log.Debug("ffmpeg", "output", stderr.Text())
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil && ctx.Err() != nil {
+		log.Warn("Interrupted: the video holds the frames encoded so far", "path", outputFile)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v", err)
+	}
+
+	log.Info("Saved video", "path", outputFile)
+	return nil
+}